@@ -10,6 +10,8 @@ package cores
 
 import (
 	"io"
+	"net"
+	"sync/atomic"
 
 	"github.com/soheilhy/cmux"
 )
@@ -35,3 +37,37 @@ func HTTP2MatchWithHeaderExclude(name, value string) cmux.MatchWriter {
 		return !matchExclude
 	}
 }
+
+// protocolMux splits the connections of a cmux between the grpc and the http listeners.
+// The http/1 connections go to the http listener. The http2 ones all go to the grpc listener
+// once GrpcListener was called, and to the http listener otherwise. Nothing is written back
+// while matching, so the split does not depend on when the clients send their headers.
+// Serve h2c on a listener of its own, see WithListener, to use it along grpc.
+type protocolMux struct {
+	cmux.CMux
+
+	grpcServed atomic.Bool
+	grpcLis    net.Listener
+	httpLis    net.Listener
+}
+
+func newProtocolMux(lis net.Listener) *protocolMux {
+	m := &protocolMux{CMux: cmux.New(lis)}
+
+	http2 := cmux.HTTP2()
+	m.grpcLis = m.Match(func(r io.Reader) bool {
+		return m.grpcServed.Load() && http2(r)
+	})
+	m.httpLis = m.Match(cmux.HTTP1Fast(), http2)
+	return m
+}
+
+// GrpcListener routes the http2 connections to the returned listener
+func (m *protocolMux) GrpcListener() net.Listener {
+	m.grpcServed.Store(true)
+	return m.grpcLis
+}
+
+func (m *protocolMux) HttpListener() net.Listener {
+	return m.httpLis
+}
@@ -2,16 +2,21 @@ package cores
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/go-puzzles/puzzles/cores/health"
 	"github.com/go-puzzles/puzzles/perror"
 	"github.com/go-puzzles/puzzles/pflags"
 	"github.com/go-puzzles/puzzles/plog"
+	"github.com/go-puzzles/puzzles/pmetrics"
 	"github.com/robfig/cron/v3"
 	"github.com/soheilhy/cmux"
 	"golang.org/x/sync/errgroup"
@@ -29,10 +34,13 @@ type mountFn struct {
 
 	fn func(ctx context.Context) error
 
-	cron    string
-	running bool
-
-	sched cron.Schedule
+	// stop is called during graceful shutdown before the mount context is cancelled.
+	stop         func() error
+	stopPriority int
+	stopTimeout  time.Duration
+	// skipStop excludes the mount from the ordered shutdown, it will only be
+	// cancelled after all the other mounts have been stopped.
+	skipStop bool
 }
 
 type CoreService struct {
@@ -40,10 +48,28 @@ type CoreService struct {
 	cancel   func()
 	opts     *Options
 	listener net.Listener
-	cmux     cmux.CMux
-
-	cron     *cron.Cron
-	mountFns []mountFn
+	cmux     *protocolMux
+
+	// rawListener is the main listener before TLS, listenAddr the address it was requested for
+	rawListener net.Listener
+	listenAddr  string
+
+	cron        *cron.Cron
+	cronCtx     context.Context
+	cronCancel  func()
+	cronMetrics *cronMetrics
+	cronWorkers []*cronWorker
+	cronRuns    sync.WaitGroup
+	mountFns    []mountFn
+
+	mu       sync.Mutex
+	mounts   []*mountState
+	stopping bool
+
+	started  atomic.Bool
+	done     chan struct{}
+	stopOnce sync.Once
+	aborted  abortState
 }
 
 type Options struct {
@@ -51,7 +77,6 @@ type Options struct {
 	ListenerAddr string
 	ServiceName  string
 	Tags         []string
-	WaitPprof    chan struct{}
 
 	GrpcListener listenerGetter
 	HttpListener listenerGetter
@@ -59,8 +84,31 @@ type Options struct {
 	HttpMux     *http.ServeMux
 	HttpHandler http.Handler
 
-	puzzles map[string]Puzzle
-	workers []Worker
+	// TLSConfig is the server config of the main listener once the service is started, nil unless WithTLS is used
+	TLSConfig *tls.Config
+
+	// Health is the registry serving /healthz and /readyz, each service has its own unless WithHealthRegistry is used
+	Health *health.Registry
+	// Metrics is the registry the built-in instrumentation records into, nil unless metrics are enabled
+	Metrics *pmetrics.Registry
+
+	puzzles      map[string]Puzzle
+	workers      []Worker
+	supervisors  []*Supervisor
+	healthChecks []healthCheck
+	shutdownCh   chan struct{}
+
+	// shared between the views of the options handed to the puzzles routed to other listeners
+	ready        *readiness
+	puzzleStates *puzzleStates
+	core         *CoreService
+
+	listeners       []*Listener
+	puzzleListeners map[string]string
+	tls             *tlsOptions
+	restart         *gracefulRestartOptions
+	httpServer      *HttpServerConfig
+	hooks           lifecycleHooks
 }
 
 // RegisterPuzzle will put Puzzle into cores service
@@ -69,17 +117,35 @@ func (o *Options) RegisterPuzzle(p Puzzle) {
 	o.puzzles[p.Name()] = p
 }
 
+// GetPuzzle returns the Puzzle registered with the given name in this cores service
+func (o *Options) GetPuzzle(name string) (Puzzle, bool) {
+	p, ok := o.puzzles[name]
+	return p, ok
+}
+
 type ServiceOption func(*Options)
 
 func NewPuzzleCore(opts ...ServiceOption) *CoreService {
-	ctx, cancel := context.WithCancel(context.TODO())
+	return NewPuzzleCoreWithContext(context.Background(), opts...)
+}
+
+// NewPuzzleCoreWithContext creates a CoreService whose lifetime is bound to ctx.
+// Cancelling ctx stops the service gracefully, the same way Shutdown does.
+func NewPuzzleCoreWithContext(ctx context.Context, opts ...ServiceOption) *CoreService {
+	ctx, cancel := context.WithCancel(ctx)
 
 	coreOpts := &Options{
 		Ctx:         ctx,
 		HttpMux:     http.NewServeMux(),
 		ServiceName: pflags.GetServiceName(),
+		Health:      health.NewRegistry(),
 		puzzles:     make(map[string]Puzzle),
 		workers:     make([]Worker, 0),
+		shutdownCh:  make(chan struct{}),
+
+		ready:           &readiness{signals: make(map[string]*readySignal)},
+		puzzleStates:    &puzzleStates{states: make(map[string]string), errs: make(map[string]error)},
+		puzzleListeners: make(map[string]string),
 	}
 	coreOpts.HttpHandler = coreOpts.HttpMux
 
@@ -87,12 +153,15 @@ func NewPuzzleCore(opts ...ServiceOption) *CoreService {
 		opt(coreOpts)
 	}
 
-	return &CoreService{
+	c := &CoreService{
 		ctx:      ctx,
 		opts:     coreOpts,
 		cancel:   cancel,
 		mountFns: make([]mountFn, 0),
+		done:     make(chan struct{}),
 	}
+	coreOpts.core = c
+	return c
 }
 
 func (c *CoreService) options() *Options {
@@ -103,40 +172,37 @@ func (c *CoreService) isHttpRun() bool {
 	return c.listener != nil
 }
 
-func (c *CoreService) listenerMatch(matcher ...cmux.Matcher) listenerGetter {
-	var lst net.Listener
-	var once sync.Once
-
-	return func() net.Listener {
-		once.Do(func() {
-			lst = c.cmux.Match(matcher...)
-		})
-
-		return lst
-	}
-}
-
-func (c *CoreService) listenerMatchWithWriter(matcher ...cmux.MatchWriter) listenerGetter {
-	var lst net.Listener
-	var once sync.Once
-
-	return func() net.Listener {
-		once.Do(func() {
-			lst = c.cmux.MatchWithWriters(matcher...)
-		})
-
-		return lst
-	}
-}
-
 func (c *CoreService) runMountFn() error {
 	grp, ctx := errgroup.WithContext(c.ctx)
 
+	c.mu.Lock()
+	if c.stopping {
+		// Shutdown was called before the mounts started
+		c.mu.Unlock()
+		c.closeListeners()
+		return nil
+	}
+
 	for _, mount := range c.mountFns {
 		mf := mount
+		if mf.stopTimeout <= 0 {
+			mf.stopTimeout = defaultStopTimeout
+		}
+
 		cc := plog.With(ctx, "Worker", mf.name)
+		cc, cancel := context.WithCancel(cc)
+		ms := &mountState{
+			mountFn: mf,
+			cancel:  cancel,
+			done:    make(chan struct{}),
+		}
+		c.mounts = append(c.mounts, ms)
+
 		grp.Go(func() (err error) {
-			err = waitContext(cc, false, mf.fn)
+			defer close(ms.done)
+			defer cancel()
+
+			err = waitContext(cc, false, mf.stopTimeout, mf.fn)
 			if err != nil {
 				plog.Errorc(cc, "handle mount error: %v", err)
 				if mf.daemon {
@@ -147,73 +213,127 @@ func (c *CoreService) runMountFn() error {
 			return nil
 		})
 	}
+	c.mu.Unlock()
 
 	return grp.Wait()
 }
 
 func (c *CoreService) serve() error {
+	if !c.started.CompareAndSwap(false, true) {
+		return perror.PackError(500, "core service already started")
+	}
+	defer close(c.done)
+
+	if err := c.parseCronWorkers(); err != nil {
+		c.closeListeners()
+		return err
+	}
+
 	c.mountFns = []mountFn{
 		c.gracefulKill(),
 	}
+	if c.opts.restart != nil {
+		if c.opts.restart.signal != nil {
+			c.mountFns = append(c.mountFns, c.gracefulRestart())
+		} else {
+			plog.Warnc(c.ctx, "Graceful restart is not supported on this platform")
+		}
+	}
+
+	if err := c.listenTLS(); err != nil {
+		c.closeListeners()
+		return err
+	}
 
 	if c.listener != nil {
-		c.cmux = cmux.New(c.listener)
-		c.opts.GrpcListener = c.listenerMatchWithWriter(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
-		c.opts.HttpListener = c.listenerMatchWithWriter(
-			HTTP1FastMatchWriter(),
-			HTTP2MatchWithHeaderExclude("content-type", "application/grpc"),
-		)
+		c.cmux = newProtocolMux(c.listener)
+		c.opts.GrpcListener = c.cmux.GrpcListener
+		c.opts.HttpListener = c.cmux.HttpListener
 
 		c.mountFns = append(c.mountFns, c.listenHttp())
 		c.mountFns = append(c.mountFns, c.listenCmux())
 	}
-	c.wrapPuzzles()
+	if err := c.listenExtra(); err != nil {
+		c.closeListeners()
+		return err
+	}
+	c.registerHealth()
+	if err := c.wrapPuzzles(); err != nil {
+		c.closeListeners()
+		return err
+	}
 	c.wrapWorker()
+	if err := runHooks(c.ctx, "OnStart", c.opts.hooks.onStart); err != nil {
+		c.closeListeners()
+		return err
+	}
+
+	go c.runReady(c.ctx)
 	c.welcome()
-	return c.runMountFn()
+	if err := c.runMountFn(); err != nil {
+		return err
+	}
+	return c.aborted.err
 }
 
-func (c *CoreService) listenHttp() mountFn {
-	return mountFn{
-		fn: func(ctx context.Context) (err error) {
-			defer func() {
-				if errors.Is(err, net.ErrClosed) {
-					err = nil
-				}
-
-				if err != nil {
-					plog.Errorc(ctx, "HttpListener serve error: %v", err)
-				}
-			}()
+func (c *CoreService) closeListeners() {
+	if c.listener != nil {
+		c.listener.Close()
+	}
+	c.closeExtra()
+}
 
-			err = http.Serve(c.opts.HttpListener(), c.opts.HttpHandler)
-			return
-		},
-		name:   "HttpListener",
-		daemon: true,
+func (c *CoreService) listenHttp() mountFn {
+	conf := c.opts.httpServerConfig()
+	if c.opts.TLSConfig != nil {
+		// connections negotiating h2 through ALPN start with the http2 preface once decrypted
+		conf.H2C = true
 	}
+
+	return serveHttp("HttpListener", c.opts.HttpListener, func() http.Handler {
+		return c.opts.HttpHandler
+	}, conf)
 }
 
 func (c *CoreService) listenCmux() mountFn {
+	return serveCmux("CmuxListener", c.cmux, c.listener)
+}
+
+func serveCmux(name string, m *protocolMux, lis net.Listener) mountFn {
 	return mountFn{
 		fn: func(ctx context.Context) (err error) {
 			defer func() {
-				if errors.Is(err, net.ErrClosed) {
+				if isClosedErr(err) {
 					err = nil
 				}
 
 				if err != nil {
-					plog.Errorc(ctx, "Cmux serve error: %v", err)
+					plog.Errorc(ctx, "%s serve error: %v", name, err)
 				}
 			}()
 
-			return c.cmux.Serve()
+			return m.Serve()
 		},
-		name:   "CmuxListener",
+		name:   name,
 		daemon: true,
+		stop: func() error {
+			m.Close()
+			if err := lis.Close(); !isClosedErr(err) {
+				return err
+			}
+			return nil
+		},
+		stopPriority: StopPriorityListener,
 	}
 }
 
+func isClosedErr(err error) bool {
+	return errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, cmux.ErrListenerClosed) ||
+		errors.Is(err, cmux.ErrServerClosed) ||
+		errors.Is(err, http.ErrServerClosed)
+}
+
 func (c *CoreService) runWithListener(lis net.Listener) error {
 	c.opts.ListenerAddr = lis.Addr().String()
 	return c.serve()
@@ -234,13 +354,23 @@ func Start[T listenEntry](srv *CoreService, addr T) error {
 		return perror.PackError(500, fmt.Sprintf("unsupported address type: %T", v))
 	}
 
-	listener, err := net.Listen("tcp", address)
+	listener, err := listen("tcp", address)
 	if err != nil {
 		return perror.WrapError(500, err, "failed to start listener")
 	}
 
-	srv.listener = listener
-	return srv.runWithListener(listener)
+	srv.listenAddr = address
+	return StartWithListener(srv, listener)
+}
+
+// StartWithListener runs the service on a listener bound by the caller
+func StartWithListener(srv *CoreService, lis net.Listener) error {
+	if srv.listenAddr == "" {
+		srv.listenAddr = lis.Addr().String()
+	}
+	srv.listener = lis
+	srv.rawListener = lis
+	return srv.runWithListener(lis)
 }
 
 func Run(srv *CoreService) error {
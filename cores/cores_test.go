@@ -0,0 +1,807 @@
+package cores
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startTestCore(t *testing.T, srv *CoreService) <-chan error {
+	srv.opts.HttpMux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Start(srv, "127.0.0.1:0")
+	}()
+
+	assert.Eventually(t, func() bool {
+		if !srv.started.Load() {
+			return false
+		}
+		resp, err := http.Get(fmt.Sprintf("http://%s/ping", srv.listener.Addr().String()))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 3*time.Second, 10*time.Millisecond)
+
+	return errCh
+}
+
+func TestCoreService_Shutdown(t *testing.T) {
+	workerStopped := make(chan struct{})
+	srv := NewPuzzleCore(
+		WithDaemonNameWorker("daemon", func(ctx context.Context) error {
+			<-ctx.Done()
+			close(workerStopped)
+			return ctx.Err()
+		}),
+	)
+	errCh := startTestCore(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	assert.Nil(t, srv.Shutdown(ctx))
+
+	select {
+	case <-workerStopped:
+	default:
+		t.Fatal("daemon worker is still running after shutdown")
+	}
+	assert.Nil(t, <-errCh)
+}
+
+func TestCoreService_ParentContextCancel(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	srv := NewPuzzleCoreWithContext(parent)
+	errCh := startTestCore(t, srv)
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.Nil(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("service did not stop after parent context was cancelled")
+	}
+}
+
+func TestCoreService_ShutdownBeforeStart(t *testing.T) {
+	srv := NewPuzzleCore()
+	assert.Nil(t, srv.Shutdown(context.Background()))
+}
+
+type orderPuzzle struct {
+	name     string
+	priority int
+	stopped  *[]string
+	mu       *sync.Mutex
+}
+
+func (p *orderPuzzle) Name() string { return p.name }
+
+func (p *orderPuzzle) Before(*Options) error { return nil }
+
+func (p *orderPuzzle) StartPuzzle(ctx context.Context, _ *Options) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (p *orderPuzzle) StopPriority() int { return p.priority }
+
+func (p *orderPuzzle) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*p.stopped = append(*p.stopped, p.name)
+	return nil
+}
+
+func TestCoreService_ShutdownOrder(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		stopped []string
+	)
+
+	register := func(name string, priority int) ServiceOption {
+		return func(o *Options) {
+			o.RegisterPuzzle(&orderPuzzle{name: name, priority: priority, stopped: &stopped, mu: &mu})
+		}
+	}
+
+	srv := NewPuzzleCore(
+		register("resource", StopPriorityResource),
+		register("server", StopPriorityServer),
+		register("discovery", StopPriorityDiscovery),
+		WithDaemonNameWorker("stubborn", func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(time.Second)
+			return nil
+		}, WithWorkerStopTimeout(100*time.Millisecond)),
+	)
+	errCh := startTestCore(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	start := time.Now()
+	assert.Nil(t, srv.Shutdown(ctx))
+	assert.Less(t, time.Since(start), time.Second)
+
+	mu.Lock()
+	assert.Equal(t, []string{"discovery", "server", "resource"}, stopped)
+	mu.Unlock()
+
+	// the stubborn worker was force closed
+	assert.NotNil(t, <-errCh)
+}
+
+type depPuzzle struct {
+	name    string
+	deps    []string
+	started *[]string
+	mu      *sync.Mutex
+}
+
+func (p *depPuzzle) Name() string { return p.name }
+
+func (p *depPuzzle) Before(*Options) error { return nil }
+
+func (p *depPuzzle) DependsOn() []string { return p.deps }
+
+func (p *depPuzzle) MarksReady() bool { return true }
+
+func (p *depPuzzle) StartPuzzle(ctx context.Context, opt *Options) error {
+	p.mu.Lock()
+	*p.started = append(*p.started, p.name)
+	p.mu.Unlock()
+
+	opt.MarkReady(p.name)
+	<-ctx.Done()
+	return nil
+}
+
+func (p *depPuzzle) Stop() error { return nil }
+
+func TestSortPuzzles(t *testing.T) {
+	puzzles := map[string]Puzzle{
+		"a": &depPuzzle{name: "a", deps: []string{"b", "missing"}},
+		"b": &depPuzzle{name: "b", deps: []string{"c"}},
+		"c": &depPuzzle{name: "c"},
+	}
+
+	sorted, err := sortPuzzles(puzzles)
+	assert.Nil(t, err)
+
+	var names []string
+	for _, p := range sorted {
+		names = append(names, p.Name())
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, names)
+
+	puzzles["c"] = &depPuzzle{name: "c", deps: []string{"a"}}
+	_, err = sortPuzzles(puzzles)
+	assert.ErrorContains(t, err, "a -> b -> c -> a")
+}
+
+func TestCoreService_PuzzleDependencies(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		started []string
+	)
+
+	register := func(name string, deps ...string) ServiceOption {
+		return func(o *Options) {
+			o.RegisterPuzzle(&depPuzzle{name: name, deps: deps, started: &started, mu: &mu})
+		}
+	}
+
+	srv := NewPuzzleCore(
+		register("gateway", "grpc", "http"),
+		register("http", "grpc"),
+		register("grpc"),
+	)
+	errCh := startTestCore(t, srv)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(started) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, srv.Shutdown(context.Background()))
+	assert.Nil(t, <-errCh)
+	assert.Equal(t, []string{"grpc", "http", "gateway"}, started)
+}
+
+func TestWorker_Supervise(t *testing.T) {
+	runs := 0
+	b := newBase("flaky", func(ctx context.Context) error {
+		runs++
+		if runs < 3 {
+			panic("boom")
+		}
+		return nil
+	}, WithRestartPolicy(RestartOnFailure), WithRestartBackoff(time.Millisecond, 5*time.Millisecond))
+
+	assert.Nil(t, b.supervise(context.Background()))
+	assert.Equal(t, 3, runs)
+	assert.Equal(t, int64(2), b.status.Restarts())
+	assert.Contains(t, b.status.LastError().Error(), "panic: boom")
+}
+
+func TestWorker_SuperviseMaxRestarts(t *testing.T) {
+	runs := 0
+	b := newBase("failing", func(ctx context.Context) error {
+		runs++
+		return fmt.Errorf("run %d failed", runs)
+	}, WithRestartPolicy(RestartAlways), WithMaxRestarts(2), WithRestartBackoff(time.Millisecond, time.Millisecond))
+
+	err := b.supervise(context.Background())
+	assert.EqualError(t, err, "run 3 failed")
+	assert.Equal(t, 3, runs)
+}
+
+func TestWorker_SuperviseBackoffReset(t *testing.T) {
+	// the default max backoff applies without WithRestartBackoff
+	var o restartOptions
+	assert.Equal(t, 5, o.nextAttempt(5, time.Second))
+	assert.Equal(t, 0, o.nextAttempt(5, defaultMaxRestartBackoff+time.Second))
+
+	b := newBase("worker", nil, WithRestartBackoff(time.Millisecond, 10*time.Millisecond))
+	assert.Equal(t, 5, b.restart.nextAttempt(5, 5*time.Millisecond))
+	assert.Equal(t, 0, b.restart.nextAttempt(5, 20*time.Millisecond))
+}
+
+func newTestCronWorker(t *testing.T, fn WorkerFunc, opts ...CronOption) (*CoreService, *cronWorker) {
+	srv := NewPuzzleCore(WithCronWorker("@every 1h", fn, opts...))
+	srv.cronCtx, srv.cronCancel = context.WithCancel(context.Background())
+	t.Cleanup(srv.cronCancel)
+
+	w := srv.opts.workers[0].(*cronWorker)
+	sched, err := w.parse()
+	assert.Nil(t, err)
+	w.sched = sched
+	return srv, w
+}
+
+func TestCronWorker_OverlapQueueOne(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+
+	srv, w := newTestCronWorker(t, func(ctx context.Context) error {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		return nil
+	}, WithCronOverlap(OverlapQueueOne))
+
+	done := make(chan struct{})
+	go func() {
+		srv.runCronWorker(w, time.Time{})
+		close(done)
+	}()
+	<-started
+
+	// the first overlapping run is queued, the second one is skipped
+	srv.runCronWorker(w, time.Time{})
+	srv.runCronWorker(w, time.Time{})
+	close(release)
+	<-done
+
+	assert.Equal(t, 2, runs)
+}
+
+func TestCronWorker_OverlapReplace(t *testing.T) {
+	started := make(chan struct{}, 2)
+	cancelled := make(chan struct{}, 2)
+
+	srv, w := newTestCronWorker(t, func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		cancelled <- struct{}{}
+		return ctx.Err()
+	}, WithCronOverlap(OverlapReplace))
+
+	go srv.runCronWorker(w, time.Time{})
+	<-started
+
+	go srv.runCronWorker(w, time.Time{})
+	<-cancelled
+	<-started
+	srv.cronCancel()
+	<-cancelled
+}
+
+func TestCronWorker_Parse(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	w := &cronWorker{base: newBase("seconds", nil), cron: "30 0 9 * * *", seconds: true, location: loc}
+
+	sched, err := w.parse()
+	assert.Nil(t, err)
+	next := sched.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2024, 1, 1, 9, 0, 30, 0, loc).Equal(next))
+
+	w = &cronWorker{base: newBase("standard", nil), cron: "30 0 9 * * *"}
+	_, err = w.parse()
+	assert.NotNil(t, err)
+}
+
+func TestCronWorker_DrainOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	srv := NewPuzzleCore(WithCronWorker("@every 1h", func(ctx context.Context) error {
+		close(started)
+		select {
+		case <-time.After(200 * time.Millisecond):
+			finished.Store(true)
+		case <-ctx.Done():
+		}
+		return nil
+	}, WithCronRunOnStartup()))
+	errCh := startTestCore(t, srv)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	assert.Nil(t, srv.Shutdown(ctx))
+	assert.True(t, finished.Load())
+	assert.Nil(t, <-errCh)
+}
+
+func TestCronWorker_TriggerAfterShutdown(t *testing.T) {
+	srv := NewPuzzleCore(WithCronWorker("@every 1h", func(context.Context) error { return nil }, WithCronName("hourly")))
+	errCh := startTestCore(t, srv)
+	assert.Nil(t, srv.Shutdown(context.Background()))
+	assert.Nil(t, <-errCh)
+
+	assert.NotNil(t, srv.options().TriggerCron("hourly"))
+}
+
+func TestCronWorker_InvalidSpec(t *testing.T) {
+	srv := NewPuzzleCore(WithCronWorker("not a spec", func(context.Context) error { return nil }))
+	assert.NotNil(t, Start(srv, "127.0.0.1:0"))
+}
+
+type memLocker struct {
+	mu    sync.Mutex
+	locks map[string]bool
+}
+
+func (l *memLocker) TryLock(_ context.Context, key string, _ time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks[key] {
+		return fmt.Errorf("lock %v is held", key)
+	}
+	l.locks[key] = true
+	return nil
+}
+
+func (l *memLocker) RenewLock(_ context.Context, _ string, _ time.Duration) error {
+	return nil
+}
+
+func TestCronWorker_StateDuringStartup(t *testing.T) {
+	srv := NewPuzzleCore(WithCronWorker("@every 1h", func(context.Context) error { return nil }, WithCronName("hourly")))
+
+	// the admin calls may come while the workers are mounted
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				srv.opts.CronJobs()
+				srv.opts.PauseCron("hourly")
+			}
+		}
+	}()
+
+	startTestCore(t, srv)
+	close(stop)
+	<-done
+	defer srv.Shutdown(context.Background())
+
+	jobs := srv.opts.CronJobs()
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, "hourly", jobs[0].Name)
+	}
+}
+
+func TestCronWorker_Singleton(t *testing.T) {
+	locker := &memLocker{locks: make(map[string]bool)}
+	var mu sync.Mutex
+	runs := 0
+	fn := func(ctx context.Context) error {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		return nil
+	}
+
+	// two replicas firing the same ticks
+	srv1, w1 := newTestCronWorker(t, fn, WithCronName("job"), WithCronSingleton(locker, time.Minute))
+	srv2, w2 := newTestCronWorker(t, fn, WithCronName("job"), WithCronSingleton(locker, time.Minute))
+
+	tick := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv1.runCronWorker(w1, tick)
+	srv2.runCronWorker(w2, tick)
+	srv2.runCronWorker(w2, tick.Add(time.Minute))
+	srv1.runCronWorker(w1, tick.Add(time.Minute))
+
+	assert.Equal(t, 2, runs)
+}
+
+func TestOptions_Runtime(t *testing.T) {
+	runs := make(chan struct{}, 1)
+	srv := NewPuzzleCore(
+		WithCronWorker("@every 1h", func(ctx context.Context) error {
+			runs <- struct{}{}
+			return nil
+		}, WithCronName("hourly")),
+		WithNameWorker("once", func(ctx context.Context) error {
+			return fmt.Errorf("failed")
+		}),
+	)
+	startTestCore(t, srv)
+	defer srv.Shutdown(context.Background())
+
+	opts := srv.options()
+	workers := opts.Workers()
+	assert.Len(t, workers, 2)
+	assert.Equal(t, "cron", workers[0].Kind)
+	assert.Eventually(t, func() bool {
+		return opts.Workers()[1].LastError == "failed"
+	}, time.Second, 10*time.Millisecond)
+
+	crons := opts.CronJobs()
+	assert.Len(t, crons, 1)
+	assert.Equal(t, "hourly", crons[0].Name)
+	assert.NotNil(t, crons[0].Next)
+
+	assert.Nil(t, opts.PauseCron("hourly"))
+	assert.True(t, opts.CronJobs()[0].Paused)
+	assert.Nil(t, opts.TriggerCron("hourly"))
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("cron job was not triggered")
+	}
+	assert.NotNil(t, opts.ResumeCron("missing"))
+}
+
+type routePuzzle struct {
+	path string
+}
+
+func (p *routePuzzle) Name() string { return "route" }
+
+func (p *routePuzzle) Before(*Options) error { return nil }
+
+func (p *routePuzzle) StartPuzzle(_ context.Context, opt *Options) error {
+	opt.HttpMux.HandleFunc(p.path, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("routed"))
+	})
+	return nil
+}
+
+func (p *routePuzzle) Stop() error { return nil }
+
+func TestCoreService_PuzzleListener(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "core.sock")
+	srv := NewPuzzleCore(
+		WithListener("admin", "tcp", "127.0.0.1:0"),
+		WithListener("local", "unix", sock),
+		WithPuzzleListener("route", "admin"),
+		func(o *Options) { o.RegisterPuzzle(&routePuzzle{path: "/routed"}) },
+	)
+	startTestCore(t, srv)
+	defer srv.Shutdown(context.Background())
+
+	admin, ok := srv.options().Listener("admin")
+	assert.True(t, ok)
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://%s/routed", admin.Addr))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 3*time.Second, 10*time.Millisecond)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/routed", srv.listener.Addr()))
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	local, _ := srv.options().Listener("local")
+	local.HttpMux.HandleFunc("/local", func(w http.ResponseWriter, r *http.Request) {})
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err = client.Get("http://unix/local")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func writeSelfSignedCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certFile, keyFile
+}
+
+func TestCoreService_TLS(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t, t.TempDir())
+	srv := NewPuzzleCore(WithTLS(certFile, keyFile))
+	srv.opts.HttpMux.HandleFunc("/proto", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Start(srv, "127.0.0.1:0")
+	}()
+	defer srv.Shutdown(context.Background())
+
+	for _, h2 := range []bool{false, true} {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: h2,
+		}}
+
+		var body []byte
+		assert.Eventually(t, func() bool {
+			if !srv.started.Load() || srv.opts.ListenerAddr == "" {
+				return false
+			}
+			resp, err := client.Get(fmt.Sprintf("https://%s/proto", srv.opts.ListenerAddr))
+			if err != nil {
+				return false
+			}
+			defer resp.Body.Close()
+			body, _ = io.ReadAll(resp.Body)
+			return resp.StatusCode == http.StatusOK
+		}, 3*time.Second, 10*time.Millisecond)
+
+		if h2 {
+			assert.Equal(t, "HTTP/2.0", string(body))
+		} else {
+			assert.Equal(t, "HTTP/1.1", string(body))
+		}
+	}
+}
+
+func TestCoreService_H2C(t *testing.T) {
+	srv := NewPuzzleCore(WithHttpServer(&HttpServerConfig{H2C: true, IdleTimeout: time.Minute}))
+	srv.opts.HttpMux.HandleFunc("/proto", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	startTestCore(t, srv)
+	defer srv.Shutdown(context.Background())
+
+	var dials atomic.Int32
+	transport := &http.Transport{
+		Protocols: new(http.Protocols),
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport}
+
+	// the requests share a single connection
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(fmt.Sprintf("http://%s/proto", srv.listener.Addr()))
+		if !assert.Nil(t, err) {
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "HTTP/2.0", string(body))
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, int32(1), dials.Load())
+}
+
+func TestCoreService_TLSWithGrpc(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer lis.Close()
+
+	c := &CoreService{cmux: newProtocolMux(lis)}
+	cfg := c.negotiateProtocol(&tls.Config{NextProtos: []string{"h2", "http/1.1"}})
+	protos := func(offered ...string) []string {
+		conf, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: offered})
+		assert.Nil(t, err)
+		return conf.NextProtos
+	}
+
+	assert.Equal(t, []string{"h2", "http/1.1"}, protos("h2", "http/1.1"))
+
+	// the h2 connections go to the grpc server once it is served
+	c.cmux.GrpcListener()
+	assert.Equal(t, []string{"http/1.1"}, protos("h2", "http/1.1"))
+	assert.Equal(t, []string{"h2", "http/1.1"}, protos("h2"))
+}
+
+func TestCoreService_HttpMaxConns(t *testing.T) {
+	srv := NewPuzzleCore(WithHttpServer(&HttpServerConfig{MaxConns: 1, IdleTimeout: time.Minute}))
+	startTestCore(t, srv)
+	defer srv.Shutdown(context.Background())
+	http.DefaultClient.CloseIdleConnections()
+	addr := srv.listener.Addr().String()
+
+	// hold the only connection with a keep-alive request
+	conn, err := net.Dial("tcp", addr)
+	if !assert.Nil(t, err) {
+		return
+	}
+	fmt.Fprintf(conn, "GET /ping HTTP/1.1\r\nHost: %s\r\n\r\n", addr)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	resp.Body.Close()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 200 * time.Millisecond}
+	_, err = client.Get(fmt.Sprintf("http://%s/ping", addr))
+	assert.NotNil(t, err)
+
+	conn.Close()
+	client.Timeout = 3 * time.Second
+	resp, err = client.Get(fmt.Sprintf("http://%s/ping", addr))
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestCoreService_HttpShutdownDrain(t *testing.T) {
+	srv := NewPuzzleCore(WithHttpServer(&HttpServerConfig{ShutdownTimeout: 300 * time.Millisecond}))
+	started := make(chan time.Duration, 2)
+	srv.opts.HttpMux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		d, _ := time.ParseDuration(r.URL.Query().Get("d"))
+		started <- d
+		time.Sleep(d)
+		w.Write([]byte("done"))
+	})
+	startTestCore(t, srv)
+	addr := srv.listener.Addr().String()
+
+	type result struct {
+		code int
+		err  error
+	}
+	get := func(d time.Duration) <-chan result {
+		ch := make(chan result, 1)
+		go func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s/slow?d=%s", addr, d))
+			if err != nil {
+				ch <- result{err: err}
+				return
+			}
+			resp.Body.Close()
+			ch <- result{code: resp.StatusCode}
+		}()
+		return ch
+	}
+
+	// the short request is drained, the long one is cut once the shutdown timeout elapsed
+	short, long := get(100*time.Millisecond), get(5*time.Second)
+	<-started
+	<-started
+
+	start := time.Now()
+	srv.Shutdown(context.Background())
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	r := <-short
+	assert.Nil(t, r.err)
+	assert.Equal(t, http.StatusOK, r.code)
+	assert.NotNil(t, (<-long).err)
+}
+
+func TestCoreService_LifecycleHooks(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) HookFunc {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	srv := NewPuzzleCore(
+		WithOnStart(record("start")),
+		WithOnReady(record("ready")),
+		WithBeforeStop(record("beforeStop")),
+		WithAfterStop(record("afterStop")),
+		WithWorker(func(ctx context.Context) error {
+			<-ctx.Done()
+			record("worker")(ctx)
+			return nil
+		}),
+	)
+	errCh := startTestCore(t, srv)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, srv.Shutdown(context.Background()))
+	assert.Nil(t, <-errCh)
+	assert.Equal(t, []string{"start", "ready", "beforeStop", "worker", "afterStop"}, calls)
+}
+
+func TestCoreService_StartHookFailure(t *testing.T) {
+	started := false
+	srv := NewPuzzleCore(
+		WithOnStart(func(context.Context) error { return fmt.Errorf("warmup failed") }),
+		WithWorker(func(ctx context.Context) error {
+			started = true
+			return nil
+		}),
+	)
+
+	err := Start(srv, "127.0.0.1:0")
+	assert.ErrorContains(t, err, "warmup failed")
+	assert.False(t, started)
+
+	srv = NewPuzzleCore(WithOnReady(func(context.Context) error { return fmt.Errorf("announce failed") }))
+	assert.ErrorContains(t, Start(srv, "127.0.0.1:0"), "announce failed")
+}
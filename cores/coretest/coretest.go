@@ -0,0 +1,187 @@
+// Package coretest runs a CoreService inside tests.
+package coretest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	bufconnAddr = "bufconn"
+	bufSize     = 1 << 20
+
+	readyTimeout = 10 * time.Second
+	stopTimeout  = 10 * time.Second
+)
+
+// Server is a CoreService started for a test, it is stopped when the test finishes
+type Server struct {
+	// Core is the running service
+	Core *cores.CoreService
+	// Options are the options of the running service
+	Options *cores.Options
+	// Addr is the address the service listens on, "bufconn" when it runs in memory
+	Addr string
+
+	// HTTP sends the requests to the service whatever the host of their url
+	HTTP *http.Client
+	// Grpc is a client connection to the grpc server of the service
+	Grpc *grpc.ClientConn
+
+	t    testing.TB
+	dial func(ctx context.Context) (net.Conn, error)
+}
+
+// Start runs a CoreService with opts on an ephemeral port and waits for all its puzzles to be ready
+func Start(t testing.TB, opts ...cores.ServiceOption) *Server {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("coretest: listen: %v", err)
+	}
+
+	addr := lis.Addr().String()
+	return start(t, lis, addr, func(ctx context.Context) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}, opts)
+}
+
+// StartBufconn runs a CoreService with opts on an in memory listener and waits for all its puzzles to be ready.
+// The puzzles dialing the service by its address, like the grpcui puzzle, are not supported.
+func StartBufconn(t testing.TB, opts ...cores.ServiceOption) *Server {
+	t.Helper()
+
+	lis := bufconn.Listen(bufSize)
+	return start(t, &bufListener{Listener: lis}, bufconnAddr, lis.DialContext, opts)
+}
+
+// bufListener reports net.ErrClosed once closed like the network listeners
+type bufListener struct {
+	*bufconn.Listener
+	closed atomic.Bool
+}
+
+func (l *bufListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil && l.closed.Load() {
+		return nil, net.ErrClosed
+	}
+	return conn, err
+}
+
+func (l *bufListener) Close() error {
+	l.closed.Store(true)
+	return l.Listener.Close()
+}
+
+func start(t testing.TB, lis net.Listener, addr string, dial func(ctx context.Context) (net.Conn, error), opts []cores.ServiceOption) *Server {
+	t.Helper()
+
+	s := &Server{Addr: addr, t: t, dial: dial}
+
+	ready := make(chan struct{})
+	opts = append(opts,
+		func(o *cores.Options) {
+			s.Options = o
+		},
+		cores.WithOnReady(func(context.Context) error {
+			close(ready)
+			return nil
+		}),
+	)
+	s.Core = cores.NewPuzzleCore(opts...)
+
+	var startErr error
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		startErr = cores.StartWithListener(s.Core, lis)
+	}()
+
+	s.HTTP = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dial(ctx)
+			},
+		},
+	}
+
+	conn, err := grpc.NewClient("passthrough:///"+addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return dial(ctx)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("coretest: grpc client: %v", err)
+	}
+	s.Grpc = conn
+
+	t.Cleanup(func() {
+		s.Grpc.Close()
+		s.HTTP.CloseIdleConnections()
+
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		defer cancel()
+		if err := s.Core.Shutdown(ctx); err != nil {
+			t.Errorf("coretest: shutdown: %v", err)
+			return
+		}
+		<-stopped
+		if startErr != nil && !t.Failed() {
+			t.Errorf("coretest: service stopped with error: %v", startErr)
+		}
+	})
+
+	timer := time.NewTimer(readyTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+	case <-stopped:
+		t.Fatalf("coretest: service stopped before being ready: %v", startErr)
+	case <-timer.C:
+		t.Fatalf("coretest: service not ready after %v", readyTimeout)
+	}
+	return s
+}
+
+// URL returns the url of path on the http server of the service
+func (s *Server) URL(path string) string {
+	return "http://" + s.Addr + path
+}
+
+// Dial opens a raw connection to the service
+func (s *Server) Dial(ctx context.Context) (net.Conn, error) {
+	return s.dial(ctx)
+}
+
+// RunCron runs the named cron job right away instead of waiting for its schedule and waits for it to finish
+func (s *Server) RunCron(name string) {
+	s.t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+	if err := s.Options.RunCron(ctx, name); err != nil {
+		s.t.Fatalf("coretest: run cron %s: %v", name, err)
+	}
+}
+
+// RunCrons runs every cron job once, see RunCron
+func (s *Server) RunCrons() {
+	s.t.Helper()
+
+	for _, job := range s.Options.CronJobs() {
+		s.RunCron(job.Name)
+	}
+}
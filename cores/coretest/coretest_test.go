@@ -0,0 +1,59 @@
+package coretest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/cores/coretest"
+	grpcpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpc-puzzle"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func withPing() cores.ServiceOption {
+	return func(o *cores.Options) {
+		o.HttpMux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("pong"))
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	for name, start := range map[string]func(testing.TB, ...cores.ServiceOption) *coretest.Server{
+		"tcp":     coretest.Start,
+		"bufconn": coretest.StartBufconn,
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := start(t, withPing(), grpcpuzzle.WithCoreGrpcPuzzle(func(*grpc.Server) {}))
+
+			resp, err := s.HTTP.Get(s.URL("/ping"))
+			if !assert.Nil(t, err) {
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "pong", string(body))
+
+			check, err := healthpb.NewHealthClient(s.Grpc).Check(context.Background(), &healthpb.HealthCheckRequest{})
+			assert.Nil(t, err)
+			assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check.GetStatus())
+		})
+	}
+}
+
+func TestServer_RunCron(t *testing.T) {
+	var runs atomic.Int32
+	s := coretest.StartBufconn(t, cores.WithCronWorker("@every 24h", func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, cores.WithCronName("daily")))
+
+	s.RunCron("daily")
+	s.RunCrons()
+	assert.Equal(t, int32(2), runs.Load())
+}
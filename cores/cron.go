@@ -0,0 +1,411 @@
+package cores
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/go-puzzles/puzzles/plog"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// OverlapPolicy decides what happens when a cron worker is scheduled while its previous run is still running
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the new run
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueueOne runs the new run once the previous one finished, at most one run is queued
+	OverlapQueueOne
+	// OverlapReplace cancels the previous run and starts the new one once it returned
+	OverlapReplace
+)
+
+var (
+	standardParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	secondsParser  = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+)
+
+type cronWorker struct {
+	*base
+	cron string
+
+	seconds      bool
+	location     *time.Location
+	overlap      OverlapPolicy
+	timeout      time.Duration
+	jitter       time.Duration
+	runOnStartup bool
+	paused       atomic.Bool
+	workerOpts   []WorkerOption
+
+	locker  CronLocker
+	lockTTL time.Duration
+
+	sched   cron.Schedule
+	entryID cron.EntryID
+
+	mu          sync.Mutex
+	running     bool
+	pending     bool
+	pendingTick time.Time
+	cancelRun   context.CancelFunc
+	runDone     chan struct{}
+}
+
+func (w *cronWorker) Name() string {
+	return w.name
+}
+
+func (w *cronWorker) Fn(ctx context.Context) error {
+	return w.fn(ctx)
+}
+
+type CronOption func(*cronWorker)
+
+// WithCronName sets the name of the cron worker, the function name is used by default.
+func WithCronName(name string) CronOption {
+	return func(w *cronWorker) {
+		w.name = name
+	}
+}
+
+// WithCronSeconds makes the spec start with a seconds field, e.g. "*/10 * * * * *".
+func WithCronSeconds() CronOption {
+	return func(w *cronWorker) {
+		w.seconds = true
+	}
+}
+
+// WithCronLocation evaluates the spec in loc instead of the local time zone.
+func WithCronLocation(loc *time.Location) CronOption {
+	return func(w *cronWorker) {
+		w.location = loc
+	}
+}
+
+// WithCronOverlap sets what happens when a run is scheduled while the previous one is still running,
+// the default is OverlapSkip.
+func WithCronOverlap(policy OverlapPolicy) CronOption {
+	return func(w *cronWorker) {
+		w.overlap = policy
+	}
+}
+
+// WithCronTimeout cancels the context of a run after timeout.
+func WithCronTimeout(timeout time.Duration) CronOption {
+	return func(w *cronWorker) {
+		w.timeout = timeout
+	}
+}
+
+// WithCronJitter delays every run by a random duration up to jitter.
+func WithCronJitter(jitter time.Duration) CronOption {
+	return func(w *cronWorker) {
+		w.jitter = jitter
+	}
+}
+
+// WithCronRunOnStartup runs the worker once when the service starts, in addition to its schedule.
+func WithCronRunOnStartup() CronOption {
+	return func(w *cronWorker) {
+		w.runOnStartup = true
+	}
+}
+
+// WithCronWorkerOptions applies the common worker options, e.g. stop timeout or health check, to the cron worker.
+func WithCronWorkerOptions(opts ...WorkerOption) CronOption {
+	return func(w *cronWorker) {
+		w.workerOpts = append(w.workerOpts, opts...)
+	}
+}
+
+// WithCronSingleton runs each scheduled tick of the worker on a single instance of the service.
+// Before running, the instance acquires a lock keyed by service name, worker name and tick that
+// expires after ttl, the lock is renewed while the run takes longer.
+// Every instance must use a spec schedule in the same time zone for their ticks to match.
+func WithCronSingleton(locker CronLocker, ttl time.Duration) CronOption {
+	return func(w *cronWorker) {
+		w.locker = locker
+		w.lockTTL = ttl
+	}
+}
+
+func WithCronWorker(spec string, fn WorkerFunc, opts ...CronOption) ServiceOption {
+	return func(cs *Options) {
+		w := &cronWorker{
+			base: newBase(funcName(fn), fn),
+			cron: spec,
+		}
+
+		for _, opt := range opts {
+			opt(w)
+		}
+		for _, opt := range w.workerOpts {
+			opt(w.base)
+		}
+
+		cs.workers = append(cs.workers, w)
+	}
+}
+
+func (w *cronWorker) parse() (cron.Schedule, error) {
+	parser := standardParser
+	if w.seconds {
+		parser = secondsParser
+	}
+
+	sched, err := parser.Parse(w.cron)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec, ok := sched.(*cron.SpecSchedule); ok && w.location != nil {
+		spec.Location = w.location
+	}
+	return sched, nil
+}
+
+// parseCronWorkers parses the spec of every cron worker so that an invalid one fails the start
+func (c *CoreService) parseCronWorkers() error {
+	for _, worker := range c.opts.workers {
+		w, ok := worker.(*cronWorker)
+		if !ok {
+			continue
+		}
+
+		sched, err := w.parse()
+		if err != nil {
+			return perror.WrapError(500, err, fmt.Sprintf("cron worker %v has an invalid spec %q", w.name, w.cron))
+		}
+		w.sched = sched
+	}
+	return nil
+}
+
+// addCronRun counts a run started outside of the cron scheduler so that the shutdown waits for it.
+// It returns false once the service is stopping, the shutdown may already be waiting for the runs.
+func (c *CoreService) addCronRun() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopping {
+		return false
+	}
+	c.cronRuns.Add(1)
+	return true
+}
+
+// cronStopTimeout is the longest stop timeout of the cron workers, it bounds the drain of the running jobs
+func (c *CoreService) cronStopTimeout() time.Duration {
+	timeout := defaultStopTimeout
+	for _, w := range c.cronWorkers {
+		timeout = max(timeout, w.stopTimeout)
+	}
+	return timeout
+}
+
+func (c *CoreService) mountCron() mountFn {
+	timeout := c.cronStopTimeout()
+	return mountFn{
+		fn: func(ctx context.Context) error {
+			defer c.cron.Stop()
+			for _, w := range c.cronWorkers {
+				if w.runOnStartup && c.addCronRun() {
+					go func() {
+						defer c.cronRuns.Done()
+						c.runCronWorker(w, time.Now().Truncate(time.Second))
+					}()
+				}
+			}
+
+			err := waitContext(ctx, true, 0, func(_ context.Context) error {
+				c.cron.Run()
+				return nil
+			})
+
+			if err != nil {
+				plog.Errorc(ctx, "Run cron error: %v", err)
+				return errors.Wrap(err, "Run cron error")
+			}
+			return nil
+		},
+		name:   "CronWorker",
+		daemon: true,
+		stop: func() error {
+			// stop scheduling new jobs and let the running ones finish,
+			// they are only cancelled once the stop timeout expires
+			stopCtx := c.cron.Stop()
+			drained := make(chan struct{})
+			go func() {
+				<-stopCtx.Done()
+				c.cronRuns.Wait()
+				close(drained)
+			}()
+
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			select {
+			case <-drained:
+			case <-timer.C:
+				plog.Warnc(c.cronCtx, "Cron jobs still running after %v, cancel them", timeout)
+				c.cronCancel()
+				<-drained
+			}
+			c.cronCancel()
+			return nil
+		},
+		stopPriority: StopPriorityWorker,
+		stopTimeout:  timeout,
+	}
+}
+
+// mountCronWorker schedules worker, c.mu publishes the cron and its workers to the admin calls
+func (c *CoreService) mountCronWorker(worker *cronWorker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cron == nil {
+		c.cron = cron.New()
+		c.cronCtx, c.cronCancel = context.WithCancel(c.ctx)
+		c.cronMetrics = newCronMetrics(c.opts.Metrics)
+	}
+
+	worker.entryID = c.cron.Schedule(worker.sched, cron.FuncJob(func() {
+		if worker.paused.Load() {
+			plog.Debugc(c.cronCtx, "Cron worker: %v is paused, skip this run", worker.name)
+			return
+		}
+
+		// Prev is the time the entry was scheduled at, the job may start a bit later
+		tick := c.cron.Entry(worker.entryID).Prev
+		if tick.IsZero() {
+			tick = time.Now().Truncate(time.Second)
+		}
+		c.runCronWorker(worker, tick)
+	}))
+	c.cronWorkers = append(c.cronWorkers, worker)
+}
+
+// mountedCron returns the cron and the workers mounted so far
+func (c *CoreService) mountedCron() (*cron.Cron, []*cronWorker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cron, c.cronWorkers
+}
+
+// acquire marks the worker as running according to its overlap policy,
+// it returns false if the run must not be started now.
+func (c *CoreService) acquireCronRun(ctx context.Context, w *cronWorker, tick time.Time) bool {
+	w.mu.Lock()
+	for w.running {
+		switch w.overlap {
+		case OverlapQueueOne:
+			queued := !w.pending
+			if queued {
+				w.pending = true
+				w.pendingTick = tick
+			}
+			w.mu.Unlock()
+			if !queued {
+				c.cronMetrics.skip(w.name)
+				plog.Warnc(ctx, "Cron worker: %v already has a queued run, skip this run", w.name)
+			}
+			return false
+		case OverlapReplace:
+			cancel, done := w.cancelRun, w.runDone
+			w.mu.Unlock()
+			plog.Infoc(ctx, "Cron worker: %v still running, replace it with a new run", w.name)
+			cancel()
+			<-done
+			w.mu.Lock()
+		default:
+			w.mu.Unlock()
+			c.cronMetrics.skip(w.name)
+			plog.Warnc(ctx, "Cron worker: %v still running, skip this run", w.name)
+			return false
+		}
+	}
+
+	w.running = true
+	w.runDone = make(chan struct{})
+	w.mu.Unlock()
+	return true
+}
+
+func (c *CoreService) runCronWorker(w *cronWorker, tick time.Time) {
+	ctx := plog.With(c.cronCtx, "Worker", w.name)
+	defer plog.Debugc(ctx, "Cron Worker: %v Next scheduler time: %v", w.name, w.sched.Next(time.Now()))
+
+	if !c.acquireCronRun(ctx, w, tick) {
+		return
+	}
+
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		w.mu.Lock()
+		w.cancelRun = cancel
+		w.mu.Unlock()
+
+		c.runCronOnce(runCtx, w, tick)
+		cancel()
+
+		w.mu.Lock()
+		if w.pending && ctx.Err() == nil {
+			w.pending = false
+			tick = w.pendingTick
+			w.mu.Unlock()
+			continue
+		}
+		w.pending = false
+		w.running = false
+		close(w.runDone)
+		w.mu.Unlock()
+		return
+	}
+}
+
+func (c *CoreService) runCronOnce(ctx context.Context, w *cronWorker, tick time.Time) {
+	if w.locker != nil {
+		release, ok := c.lockCronTick(ctx, w, tick)
+		if !ok {
+			return
+		}
+		defer release()
+	}
+
+	if w.jitter > 0 {
+		timer := time.NewTimer(rand.N(w.jitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := waitContext(ctx, false, w.stopTimeout, func(ctx context.Context) error {
+		return safeRun(ctx, w.name, w.fn)
+	})
+	if err != nil && !isCanceled(err) {
+		w.status.setLastErr(err)
+	}
+	c.cronMetrics.observe(w.name, start, err)
+
+	if err != nil {
+		plog.Errorc(ctx, "Run cron worker: %v error: %v", w.name, err)
+	}
+}
@@ -0,0 +1,63 @@
+package cores
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-puzzles/puzzles/plog"
+)
+
+const defaultCronLockTTL = time.Minute
+
+// CronLocker is a distributed lock used by WithCronSingleton, goredis.PuzzleRedisClient implements it
+type CronLocker interface {
+	TryLock(ctx context.Context, key string, expiration time.Duration) error
+	RenewLock(ctx context.Context, key string, expiration time.Duration) error
+}
+
+func (c *CoreService) cronLockKey(w *cronWorker, tick time.Time) string {
+	return fmt.Sprintf("cron:%s:%s:%d", c.opts.ServiceName, w.name, tick.Unix())
+}
+
+// lockCronTick acquires the lock of the tick, it returns false if the tick is handled by another instance.
+// The lock is renewed until release is called, it is then left to expire so that an instance
+// firing the same tick later can not acquire it again.
+func (c *CoreService) lockCronTick(ctx context.Context, w *cronWorker, tick time.Time) (release func(), ok bool) {
+	ttl := w.lockTTL
+	if ttl <= 0 {
+		ttl = defaultCronLockTTL
+	}
+	key := c.cronLockKey(w, tick)
+
+	if err := w.locker.TryLock(ctx, key, ttl); err != nil {
+		c.cronMetrics.skip(w.name)
+		plog.Infoc(ctx, "Cron worker: %v skip tick %v, lock %v not acquired: %v", w.name, tick, key, err)
+		return nil, false
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.locker.RenewLock(context.WithoutCancel(ctx), key, ttl); err != nil {
+					plog.Warnc(ctx, "Cron worker: %v renew lock %v error: %v", w.name, key, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}, true
+}
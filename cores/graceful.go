@@ -4,17 +4,182 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"sort"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/go-puzzles/puzzles/perror"
 	"github.com/go-puzzles/puzzles/plog"
 	"github.com/pkg/errors"
 )
 
+// Stop priorities used during graceful shutdown. Components with a lower
+// priority are stopped first, components sharing a priority are stopped concurrently.
+const (
+	// StopPriorityDiscovery deregisters the service so that no new traffic is routed to it
+	StopPriorityDiscovery = 100
+	// StopPriorityServer stops the puzzles accepting http/grpc requests
+	StopPriorityServer = 200
+	// StopPriorityListener closes the cmux and the underlying listener
+	StopPriorityListener = 300
+	// StopPriorityWorker drains the background workers
+	StopPriorityWorker = 400
+	// StopPriorityResource closes the shared resources like db pools
+	StopPriorityResource = 500
+)
+
+const defaultStopTimeout = 5 * time.Second
+
+// HasStopPriority can be implemented by a Puzzle to declare when it should be stopped.
+// Puzzles without it are stopped with StopPriorityServer.
+type HasStopPriority interface {
+	StopPriority() int
+}
+
+// HasStopTimeout can be implemented by a Puzzle to declare how long it may take to drain.
+type HasStopTimeout interface {
+	StopTimeout() time.Duration
+}
+
+type mountState struct {
+	mountFn
+	cancel func()
+	done   chan struct{}
+}
+
+type stopReport struct {
+	name     string
+	priority int
+	elapsed  time.Duration
+	forced   bool
+	err      error
+}
+
+// GracefulStopPuzzle stops all puzzles and workers in stop priority order.
 func (c *CoreService) GracefulStopPuzzle() {
-	for name, puzzle := range c.opts.puzzles {
-		if err := puzzle.Stop(); err != nil {
-			plog.Errorc(c.ctx, "stop %v error: %v", name, err)
+	c.gracefulStop(context.Background())
+}
+
+// gracefulStop stops puzzles, closes the listeners and drains workers in stop priority order.
+// It is safe to call it multiple times, only the first call takes effect.
+func (c *CoreService) gracefulStop(ctx context.Context) {
+	c.stopOnce.Do(func() {
+		plog.Infoc(c.ctx, "Graceful stopping puzzles...")
+
+		// flip the readiness to failing before anything is stopped
+		close(c.opts.shutdownCh)
+		runStopHooks(ctx, "BeforeStop", c.opts.hooks.beforeStop)
+
+		c.mu.Lock()
+		c.stopping = true
+		mounts := append([]*mountState(nil), c.mounts...)
+		c.mu.Unlock()
+
+		reports := c.stopMounts(ctx, mounts)
+		c.cancel()
+		runStopHooks(ctx, "AfterStop", c.opts.hooks.afterStop)
+
+		for _, r := range reports {
+			if r.err != nil {
+				plog.Errorc(c.ctx, "Shutdown report: %v priority=%d elapsed=%v forced=%v err=%v", r.name, r.priority, r.elapsed, r.forced, r.err)
+			} else {
+				plog.Infoc(c.ctx, "Shutdown report: %v priority=%d elapsed=%v forced=%v", r.name, r.priority, r.elapsed, r.forced)
+			}
+		}
+
+		plog.Infoc(c.ctx, "Graceful stopped puzzles successfully")
+	})
+}
+
+func (c *CoreService) stopMounts(ctx context.Context, mounts []*mountState) []stopReport {
+	stages := make(map[int][]*mountState)
+	for _, m := range mounts {
+		if m.skipStop {
+			continue
 		}
+		stages[m.stopPriority] = append(stages[m.stopPriority], m)
+	}
+
+	priorities := make([]int, 0, len(stages))
+	for p := range stages {
+		priorities = append(priorities, p)
+	}
+	sort.Ints(priorities)
+
+	reports := make([]stopReport, 0, len(mounts))
+	for _, p := range priorities {
+		stage := stages[p]
+		stageReports := make([]stopReport, len(stage))
+
+		var wg sync.WaitGroup
+		for i, m := range stage {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stageReports[i] = stopMount(ctx, m)
+			}()
+		}
+		wg.Wait()
+
+		reports = append(reports, stageReports...)
+	}
+
+	return reports
+}
+
+// stopMount stops a single mount and waits for it to drain within its stop timeout.
+func stopMount(ctx context.Context, m *mountState) stopReport {
+	start := time.Now()
+	report := stopReport{name: m.name, priority: m.stopPriority}
+
+	timer := time.NewTimer(m.stopTimeout)
+	defer timer.Stop()
+
+	stopped := make(chan error, 1)
+	go func() {
+		var err error
+		if m.stop != nil {
+			err = m.stop()
+		}
+		m.cancel()
+		stopped <- err
+	}()
+
+	select {
+	case report.err = <-stopped:
+		select {
+		case <-m.done:
+		case <-timer.C:
+			report.forced = true
+		case <-ctx.Done():
+			report.forced = true
+		}
+	case <-timer.C:
+		report.forced = true
+	case <-ctx.Done():
+		report.forced = true
+	}
+	m.cancel()
+
+	report.elapsed = time.Since(start)
+	return report
+}
+
+// Shutdown gracefully stops the service without relying on OS signals.
+// It returns once all workers have drained or ctx is done.
+func (c *CoreService) Shutdown(ctx context.Context) error {
+	c.gracefulStop(ctx)
+
+	if !c.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return perror.WrapError(500, ctx.Err(), "wait for service shutdown")
 	}
 }
 
@@ -31,28 +196,19 @@ func (c *CoreService) gracefulKill() mountFn {
 				syscall.SIGTERM,
 				syscall.SIGQUIT,
 			)
+			defer signal.Stop(ch)
 
 			select {
 			case sg := <-ch:
-				plog.Infoc(c.ctx, "Graceful stopping puzzles...")
-				c.GracefulStopPuzzle()
-
-				if c.cmux != nil {
-					c.cmux.Close()
-				}
-
-				if c.listener != nil {
-					c.listener.Close()
-				}
-
-				c.cancel()
-
-				plog.Infoc(c.ctx, "Graceful stopped puzzles successfully")
+				c.gracefulStop(context.Background())
 				return errors.Errorf("Signal: %s", sg.String())
 			case <-ctx.Done():
-				return ctx.Err()
+				// the parent context was cancelled or Shutdown was called
+				c.gracefulStop(context.Background())
+				return nil
 			}
 		},
-		daemon: true,
+		daemon:   true,
+		skipStop: true,
 	}
 }
@@ -0,0 +1,44 @@
+package cores
+
+import (
+	"github.com/go-puzzles/puzzles/cores/health"
+)
+
+const (
+	healthzUrl = "/healthz"
+	readyzUrl  = "/readyz"
+)
+
+type healthCheck struct {
+	name string
+	fn   health.CheckFunc
+	opts []health.CheckerOption
+}
+
+// WithHealthRegistry makes the service use the given health registry instead of a registry of its own
+func WithHealthRegistry(registry *health.Registry) ServiceOption {
+	return func(o *Options) {
+		o.Health = registry
+	}
+}
+
+// WithHealthCheck registers a named checker into the health registry of the service
+func WithHealthCheck(name string, fn health.CheckFunc, opts ...health.CheckerOption) ServiceOption {
+	return func(o *Options) {
+		o.healthChecks = append(o.healthChecks, healthCheck{name: name, fn: fn, opts: opts})
+	}
+}
+
+// ShuttingDown returns a channel which is closed as soon as the graceful shutdown begins
+func (o *Options) ShuttingDown() <-chan struct{} {
+	return o.shutdownCh
+}
+
+func (c *CoreService) registerHealth() {
+	for _, hc := range c.opts.healthChecks {
+		c.opts.Health.Register(hc.name, hc.fn, hc.opts...)
+	}
+
+	c.opts.HttpMux.Handle(healthzUrl, health.Handler(c.opts.Health, health.Liveness))
+	c.opts.HttpMux.Handle(readyzUrl, health.Handler(c.opts.Health, health.Readiness, health.WithShutdownSignal(c.opts.shutdownCh)))
+}
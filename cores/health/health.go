@@ -0,0 +1,253 @@
+// Package health provides a registry of named health checkers which are
+// evaluated concurrently and served as json for liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-puzzles/puzzles/perror"
+)
+
+type Kind int
+
+const (
+	// Liveness checks tell whether the process is alive. They are also part of readiness.
+	Liveness Kind = iota + 1
+	// Readiness checks tell whether the process is able to serve requests.
+	Readiness
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Liveness:
+		return "liveness"
+	case Readiness:
+		return "readiness"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+
+	defaultTimeout = 2 * time.Second
+)
+
+// CheckFunc returns nil when the checked dependency is healthy
+type CheckFunc func(ctx context.Context) error
+
+type checker struct {
+	name     string
+	fn       CheckFunc
+	kind     Kind
+	critical bool
+	timeout  time.Duration
+}
+
+type CheckerOption func(*checker)
+
+// WithLiveness registers the checker as a liveness check, by default checkers are readiness checks.
+func WithLiveness() CheckerOption {
+	return func(c *checker) {
+		c.kind = Liveness
+	}
+}
+
+// WithNonCritical makes a failed check reported without failing the overall status.
+func WithNonCritical() CheckerOption {
+	return func(c *checker) {
+		c.critical = false
+	}
+}
+
+// WithTimeout sets how long a single check may run before it is considered failed.
+func WithTimeout(timeout time.Duration) CheckerOption {
+	return func(c *checker) {
+		c.timeout = timeout
+	}
+}
+
+type Registry struct {
+	mu       sync.RWMutex
+	checkers map[string]*checker
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process wide registry, give it to cores.WithHealthRegistry to share the checks between services.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		checkers: make(map[string]*checker),
+	}
+}
+
+// Register adds a named checker. It replaces the previously registered checker with the same name.
+func (r *Registry) Register(name string, fn CheckFunc, opts ...CheckerOption) {
+	c := &checker{
+		name:     name,
+		fn:       fn,
+		kind:     Readiness,
+		critical: true,
+		timeout:  defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkers[name] = c
+}
+
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.checkers, name)
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks,omitempty"`
+}
+
+func (r *Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+func (r *Registry) selectCheckers(kind Kind) []*checker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	checkers := make([]*checker, 0, len(r.checkers))
+	for _, c := range r.checkers {
+		// liveness checks are part of the readiness as well
+		if c.kind == kind || kind == Readiness {
+			checkers = append(checkers, c)
+		}
+	}
+
+	sort.Slice(checkers, func(i, j int) bool {
+		return checkers[i].name < checkers[j].name
+	})
+	return checkers
+}
+
+// Check runs all the checkers of the given kind concurrently and reports their results.
+func (r *Registry) Check(ctx context.Context, kind Kind) *Report {
+	checkers := r.selectCheckers(kind)
+
+	results := make([]*CheckResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, Checks: make(map[string]*CheckResult, len(checkers))}
+	for i, c := range checkers {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK && c.critical {
+			report.Status = StatusFail
+		}
+	}
+
+	return report
+}
+
+func (c *checker) run(ctx context.Context) *CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- perror.PackError(perror.CodeUnknown, "health check panic")
+			}
+		}()
+		errCh <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = perror.WrapError(perror.CodeUnknown, ctx.Err(), "health check timeout")
+	}
+
+	result := &CheckResult{
+		Status:   StatusOK,
+		Critical: c.critical,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+type handlerOption struct {
+	shutdown <-chan struct{}
+}
+
+type HandlerOption func(*handlerOption)
+
+// WithShutdownSignal makes the handler report StatusShuttingDown once ch is closed.
+func WithShutdownSignal(ch <-chan struct{}) HandlerOption {
+	return func(o *handlerOption) {
+		o.shutdown = ch
+	}
+}
+
+// Handler serves the report of the checks of the given kind as json.
+// It responds 200 when healthy and 503 otherwise.
+func Handler(r *Registry, kind Kind, opts ...HandlerOption) http.Handler {
+	opt := &handlerOption{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var report *Report
+		select {
+		case <-opt.shutdown:
+			report = &Report{Status: StatusShuttingDown}
+		default:
+			report = r.Check(req.Context(), kind)
+		}
+
+		code := http.StatusOK
+		if !report.Healthy() {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Check(t *testing.T) {
+	r := NewRegistry()
+	r.Register("db", func(ctx context.Context) error { return nil })
+	r.Register("process", func(ctx context.Context) error { return nil }, WithLiveness())
+	r.Register("cache", func(ctx context.Context) error { return errors.New("cache down") }, WithNonCritical())
+
+	report := r.Check(context.Background(), Liveness)
+	assert.True(t, report.Healthy())
+	assert.Len(t, report.Checks, 1)
+
+	report = r.Check(context.Background(), Readiness)
+	assert.True(t, report.Healthy())
+	assert.Len(t, report.Checks, 3)
+	assert.Equal(t, StatusFail, report.Checks["cache"].Status)
+	assert.Equal(t, "cache down", report.Checks["cache"].Error)
+
+	r.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(10*time.Millisecond))
+
+	report = r.Check(context.Background(), Readiness)
+	assert.False(t, report.Healthy())
+	assert.Equal(t, StatusFail, report.Checks["slow"].Status)
+
+	r.Unregister("slow")
+	assert.True(t, r.Check(context.Background(), Readiness).Healthy())
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Register("db", func(ctx context.Context) error { return nil })
+
+	shutdown := make(chan struct{})
+	handler := Handler(r, Readiness, WithShutdownSignal(shutdown))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	report := &Report{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), report))
+	assert.Equal(t, StatusOK, report.Checks["db"].Status)
+
+	close(shutdown)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), report))
+	assert.Equal(t, StatusShuttingDown, report.Status)
+}
@@ -0,0 +1,110 @@
+package cores
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/go-puzzles/puzzles/plog"
+)
+
+// HookFunc is run at a stage of the CoreService lifecycle
+type HookFunc func(ctx context.Context) error
+
+type lifecycleHooks struct {
+	onStart    []HookFunc
+	onReady    []HookFunc
+	beforeStop []HookFunc
+	afterStop  []HookFunc
+}
+
+// WithOnStart runs fn once the listeners are bound and the puzzles prepared, before any of them is started.
+// An error aborts the startup and is returned by Start or Run.
+func WithOnStart(fn HookFunc) ServiceOption {
+	return func(o *Options) {
+		o.hooks.onStart = append(o.hooks.onStart, fn)
+	}
+}
+
+// WithOnReady runs fn once all the puzzles are ready.
+// An error stops the service gracefully and is returned by Start or Run.
+func WithOnReady(fn HookFunc) ServiceOption {
+	return func(o *Options) {
+		o.hooks.onReady = append(o.hooks.onReady, fn)
+	}
+}
+
+// WithBeforeStop runs fn when the graceful stop begins, before any puzzle or worker is stopped.
+// Errors are logged and the stop goes on.
+func WithBeforeStop(fn HookFunc) ServiceOption {
+	return func(o *Options) {
+		o.hooks.beforeStop = append(o.hooks.beforeStop, fn)
+	}
+}
+
+// WithAfterStop runs fn once all the puzzles and workers are stopped.
+// Errors are logged and the stop goes on.
+func WithAfterStop(fn HookFunc) ServiceOption {
+	return func(o *Options) {
+		o.hooks.afterStop = append(o.hooks.afterStop, fn)
+	}
+}
+
+// runHooks runs the hooks in order and returns the first error
+func runHooks(ctx context.Context, stage string, hooks []HookFunc) error {
+	for i, fn := range hooks {
+		if err := safeRun(ctx, stage, WorkerFunc(fn)); err != nil {
+			return perror.WrapError(500, err, fmt.Sprintf("%s hook #%d", stage, i))
+		}
+	}
+	return nil
+}
+
+// runStopHooks runs all the hooks, the errors are only logged
+func runStopHooks(ctx context.Context, stage string, hooks []HookFunc) {
+	for i, fn := range hooks {
+		if err := safeRun(ctx, stage, WorkerFunc(fn)); err != nil {
+			plog.Errorc(ctx, "%s hook #%d error: %v", stage, i, err)
+		}
+	}
+}
+
+// waitAllReady blocks until all the puzzles are ready or ctx is done
+func (c *CoreService) waitAllReady(ctx context.Context) error {
+	for name := range c.opts.puzzles {
+		if err := c.opts.WaitReady(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runReady runs the OnReady hooks once all the puzzles are ready, then notifies the parent process
+// of a graceful restart. A failing hook stops the service.
+func (c *CoreService) runReady(ctx context.Context) {
+	if err := c.waitAllReady(ctx); err != nil {
+		return
+	}
+
+	if err := runHooks(ctx, "OnReady", c.opts.hooks.onReady); err != nil {
+		plog.Errorc(ctx, "Stopping the service: %v", err)
+		c.abort(err)
+		return
+	}
+
+	c.notifyReady(ctx)
+}
+
+type abortState struct {
+	once sync.Once
+	err  error
+}
+
+// abort stops the service gracefully, err is returned by serve once the mounts are stopped
+func (c *CoreService) abort(err error) {
+	c.aborted.once.Do(func() {
+		c.aborted.err = err
+	})
+	c.gracefulStop(context.Background())
+}
@@ -0,0 +1,129 @@
+package cores
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-puzzles/puzzles/pflags"
+	"github.com/go-puzzles/puzzles/plog"
+	"golang.org/x/net/netutil"
+)
+
+var (
+	httpReadTimeout       = pflags.Duration("httpReadTimeout", 0, "Maximum duration for reading an entire http request, 0 means no timeout")
+	httpReadHeaderTimeout = pflags.Duration("httpReadHeaderTimeout", 10*time.Second, "Maximum duration for reading the http request headers")
+	httpWriteTimeout      = pflags.Duration("httpWriteTimeout", 0, "Maximum duration before timing out the writes of an http response, 0 means no timeout")
+	httpIdleTimeout       = pflags.Duration("httpIdleTimeout", 2*time.Minute, "Maximum duration to wait for the next request on a keep-alive http connection")
+	httpMaxHeaderBytes    = pflags.Int("httpMaxHeaderBytes", http.DefaultMaxHeaderBytes, "Maximum size of the http request headers")
+	httpH2C               = pflags.Bool("httpH2C", true, "Whether to serve cleartext http2 on the listeners not serving grpc")
+	httpMaxConns          = pflags.Int("httpMaxConns", 0, "Maximum concurrent connections of each http listener, 0 means no limit")
+	httpShutdownTimeout   = pflags.Duration("httpShutdownTimeout", defaultStopTimeout, "Maximum duration to drain the in-flight http requests on shutdown")
+)
+
+// HttpServerConfig configures the http.Server behind the http listeners
+type HttpServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// H2C serves http2 with prior knowledge on the cleartext listeners not serving grpc
+	H2C bool
+	// MaxConns limits the connections served at once by each http listener, 0 means no limit
+	MaxConns int
+	// ShutdownTimeout bounds the drain of the in-flight requests on shutdown, 0 means the default stop timeout
+	ShutdownTimeout time.Duration
+}
+
+// DefaultHttpServerConfig returns the http server config read from the flags
+func DefaultHttpServerConfig() *HttpServerConfig {
+	return &HttpServerConfig{
+		ReadTimeout:       httpReadTimeout(),
+		ReadHeaderTimeout: httpReadHeaderTimeout(),
+		WriteTimeout:      httpWriteTimeout(),
+		IdleTimeout:       httpIdleTimeout(),
+		MaxHeaderBytes:    httpMaxHeaderBytes.Value(),
+		H2C:               httpH2C.Value(),
+		MaxConns:          httpMaxConns.Value(),
+		ShutdownTimeout:   httpShutdownTimeout(),
+	}
+}
+
+// WithHttpServer overrides the http server config read from the flags
+func WithHttpServer(conf *HttpServerConfig) ServiceOption {
+	return func(o *Options) {
+		o.httpServer = conf
+	}
+}
+
+func (o *Options) httpServerConfig() HttpServerConfig {
+	if o.httpServer == nil {
+		o.httpServer = DefaultHttpServerConfig()
+	}
+	return *o.httpServer
+}
+
+func (conf HttpServerConfig) newServer() *http.Server {
+	srv := &http.Server{
+		ReadTimeout:       conf.ReadTimeout,
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+		MaxHeaderBytes:    conf.MaxHeaderBytes,
+	}
+	if conf.H2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return srv
+}
+
+// serveHttp serves the handler on the http listener, handler is called once the mount runs
+// so that the puzzles can wrap it in their Before. The in-flight requests are drained on stop,
+// those still running after the shutdown timeout are cut.
+func serveHttp(name string, lis listenerGetter, handler func() http.Handler, conf HttpServerConfig) mountFn {
+	srv := conf.newServer()
+	timeout := conf.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+
+	return mountFn{
+		stopPriority: StopPriorityListener,
+		stopTimeout:  timeout,
+		stop: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := srv.Shutdown(ctx); err != nil {
+				// cut the requests still running once the drain timed out
+				srv.Close()
+				return err
+			}
+			return nil
+		},
+		fn: func(ctx context.Context) (err error) {
+			defer func() {
+				if isClosedErr(err) {
+					err = nil
+				}
+
+				if err != nil {
+					plog.Errorc(ctx, "%s serve error: %v", name, err)
+				}
+			}()
+
+			l := lis()
+			if conf.MaxConns > 0 {
+				l = netutil.LimitListener(l, conf.MaxConns)
+			}
+
+			srv.Handler = handler()
+			err = srv.Serve(l)
+			return
+		},
+		name:   name,
+		daemon: true,
+	}
+}
@@ -0,0 +1,158 @@
+package cores
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/go-puzzles/puzzles/plog"
+)
+
+// Listener is an additional address the service listens on besides the one given to Start.
+// Like the main listener, it serves both grpc and http through its own cmux.
+// It also serves h2c to the http handler when no grpc puzzle is routed to it.
+type Listener struct {
+	Name    string
+	Network string
+	// Addr is the address the listener is bound to once the service is started
+	Addr string
+	// listenAddr is the address the listener was requested for, used to hand it over on graceful restart
+	listenAddr string
+
+	GrpcListener listenerGetter
+	HttpListener listenerGetter
+
+	HttpMux     *http.ServeMux
+	HttpHandler http.Handler
+
+	lis  net.Listener
+	cmux *protocolMux
+}
+
+// WithListener adds a listener, network is "tcp" or "unix", e.g.
+// WithListener("admin", "tcp", "127.0.0.1:9090") or WithListener("local", "unix", "/run/app.sock").
+// Use WithPuzzleListener to route puzzles to it.
+func WithListener(name, network, addr string) ServiceOption {
+	return func(o *Options) {
+		mux := http.NewServeMux()
+		o.listeners = append(o.listeners, &Listener{
+			Name:        name,
+			Network:     network,
+			Addr:        addr,
+			HttpMux:     mux,
+			HttpHandler: mux,
+		})
+	}
+}
+
+// WithPuzzleListener makes the named puzzle use the named listener instead of the main one.
+// The puzzle sees the HttpMux, HttpHandler, grpc and http listeners and address of that listener in its Options.
+func WithPuzzleListener(puzzle, listener string) ServiceOption {
+	return func(o *Options) {
+		o.puzzleListeners[puzzle] = listener
+	}
+}
+
+// Listener returns the additional listener registered with the given name
+func (o *Options) Listener(name string) (*Listener, bool) {
+	for _, l := range o.listeners {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// PuzzleAddr returns the network and address of the listener the named puzzle is routed to,
+// and its TLS config if TLS is terminated on it.
+func (o *Options) PuzzleAddr(name string) (network, addr string, tlsConfig *tls.Config) {
+	main := o
+	if o.core != nil {
+		main = o.core.opts
+	}
+
+	if l, ok := main.Listener(main.puzzleListeners[name]); ok {
+		return l.Network, l.Addr, nil
+	}
+	return "tcp", main.ListenerAddr, main.TLSConfig
+}
+
+// forListener returns a view of the options for a puzzle routed to l
+func (o *Options) forListener(l *Listener) *Options {
+	v := *o
+	v.ListenerAddr = l.Addr
+	v.GrpcListener = l.GrpcListener
+	v.HttpListener = l.HttpListener
+	v.HttpMux = l.HttpMux
+	v.HttpHandler = l.HttpHandler
+	v.TLSConfig = nil
+	return &v
+}
+
+func (c *CoreService) puzzleOptions(p Puzzle) (*Options, error) {
+	name, ok := c.opts.puzzleListeners[p.Name()]
+	if !ok {
+		return c.opts, nil
+	}
+
+	l, ok := c.opts.Listener(name)
+	if !ok {
+		return nil, perror.PackError(500, fmt.Sprintf("puzzle %s is routed to unknown listener %s", p.Name(), name))
+	}
+	return c.opts.forListener(l), nil
+}
+
+func listen(network, addr string) (net.Listener, error) {
+	if lis, ok := inheritedListener(network, addr); ok {
+		plog.Debugf("Listener %s:%s inherited from the parent process", network, addr)
+		return lis, nil
+	}
+
+	if strings.HasPrefix(network, "unix") {
+		// remove the socket left behind by a previous process
+		if fi, err := os.Stat(addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+	}
+
+	return net.Listen(network, addr)
+}
+
+// listenExtra binds the additional listeners and mounts their servers
+func (c *CoreService) listenExtra() error {
+	for _, l := range c.opts.listeners {
+		lis, err := listen(l.Network, l.Addr)
+		if err != nil {
+			c.closeExtra()
+			return perror.WrapError(500, err, fmt.Sprintf("failed to start listener %s", l.Name))
+		}
+
+		l.lis = lis
+		l.listenAddr = l.Addr
+		l.Addr = lis.Addr().String()
+		l.cmux = newProtocolMux(lis)
+		l.GrpcListener = l.cmux.GrpcListener
+		l.HttpListener = l.cmux.HttpListener
+
+		c.mountFns = append(c.mountFns,
+			serveHttp(fmt.Sprintf("HttpListener:%s", l.Name), l.HttpListener, func() http.Handler { return l.HttpHandler }, c.opts.httpServerConfig()),
+			serveCmux(fmt.Sprintf("CmuxListener:%s", l.Name), l.cmux, l.lis),
+		)
+
+		plog.Debugf("Listener %s enabled. Addr=%s://%s", l.Name, l.Network, l.Addr)
+	}
+
+	return nil
+}
+
+func (c *CoreService) closeExtra() {
+	for _, l := range c.opts.listeners {
+		if l.lis != nil {
+			l.lis.Close()
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cores
+
+import (
+	"time"
+
+	"github.com/go-puzzles/puzzles/pmetrics"
+)
+
+type cronMetrics struct {
+	runs     *pmetrics.Counter
+	failures *pmetrics.Counter
+	skipped  *pmetrics.Counter
+	duration *pmetrics.Histogram
+}
+
+func newCronMetrics(r *pmetrics.Registry) *cronMetrics {
+	if r == nil {
+		return nil
+	}
+
+	return &cronMetrics{
+		runs:     r.NewCounter("cron_job_runs_total", "Total number of cron job runs.", "job"),
+		failures: r.NewCounter("cron_job_failures_total", "Total number of failed cron job runs.", "job"),
+		skipped:  r.NewCounter("cron_job_skipped_total", "Total number of cron job runs skipped because the previous run was still running.", "job"),
+		duration: r.NewHistogram("cron_job_duration_seconds", "Duration of cron job runs in seconds.", pmetrics.DefBuckets, "job"),
+	}
+}
+
+func (m *cronMetrics) observe(job string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	m.runs.Inc(job)
+	m.duration.Observe(time.Since(start).Seconds(), job)
+	if err != nil {
+		m.failures.Inc(job)
+	}
+}
+
+func (m *cronMetrics) skip(job string) {
+	if m == nil {
+		return
+	}
+
+	m.skipped.Inc(job)
+}
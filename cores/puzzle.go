@@ -2,7 +2,12 @@ package cores
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/go-puzzles/puzzles/perror"
 	"github.com/go-puzzles/puzzles/plog"
 )
 
@@ -13,21 +18,212 @@ type Puzzle interface {
 	Stop() error
 }
 
-func (c *CoreService) wrapPuzzles() {
-	for _, puzzle := range c.opts.puzzles {
-		err := puzzle.Before(c.opts)
+// HasDependencies can be implemented by a Puzzle which must only be started
+// once the puzzles it depends on are ready. Unregistered dependencies are ignored.
+type HasDependencies interface {
+	DependsOn() []string
+}
+
+// HasReadiness can be implemented by a Puzzle calling MarkReady itself once it is able to serve.
+// The other puzzles are ready as soon as their StartPuzzle is running.
+type HasReadiness interface {
+	MarksReady() bool
+}
+
+func marksReady(p Puzzle) bool {
+	r, ok := p.(HasReadiness)
+	return ok && r.MarksReady()
+}
+
+type readySignal struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+type readiness struct {
+	mu      sync.Mutex
+	signals map[string]*readySignal
+}
+
+func (o *Options) readySignal(name string) *readySignal {
+	o.ready.mu.Lock()
+	defer o.ready.mu.Unlock()
+
+	rs, ok := o.ready.signals[name]
+	if !ok {
+		rs = &readySignal{ch: make(chan struct{})}
+		o.ready.signals[name] = rs
+	}
+	return rs
+}
+
+// MarkReady marks the puzzle as ready and releases everyone waiting on it.
+// It is called by the puzzles implementing HasReadiness once they are able to serve,
+// puzzles returning from StartPuzzle without error are marked ready automatically.
+func (o *Options) MarkReady(name string) {
+	rs := o.readySignal(name)
+	rs.once.Do(func() {
+		close(rs.ch)
+	})
+}
+
+// WaitReady blocks until the named puzzle is ready or ctx is done.
+func (o *Options) WaitReady(ctx context.Context, name string) error {
+	select {
+	case <-o.readySignal(name).ch:
+		return nil
+	case <-ctx.Done():
+		return perror.WrapError(500, ctx.Err(), fmt.Sprintf("wait for puzzle %s ready", name))
+	}
+}
+
+func puzzleDependencies(p Puzzle) []string {
+	if d, ok := p.(HasDependencies); ok {
+		return d.DependsOn()
+	}
+	return nil
+}
+
+// sortPuzzles returns the registered puzzles in topological order of their dependencies.
+func sortPuzzles(puzzles map[string]Puzzle) ([]Puzzle, error) {
+	names := make([]string, 0, len(puzzles))
+	for name := range puzzles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		state  = make(map[string]int, len(names))
+		sorted = make([]Puzzle, 0, len(names))
+		path   []string
+		visit  func(name string) error
+	)
+
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			idx := 0
+			for i, n := range path {
+				if n == name {
+					idx = i
+					break
+				}
+			}
+			cycle := append(path[idx:], name)
+			return perror.PackError(500, fmt.Sprintf("puzzle dependency cycle detected: %s", strings.Join(cycle, " -> ")))
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+
+		p := puzzles[name]
+		for _, dep := range puzzleDependencies(p) {
+			if _, ok := puzzles[dep]; !ok {
+				plog.Debugf("Puzzle %s depends on %s which is not registered, ignored.", name, dep)
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+		sorted = append(sorted, p)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+func (c *CoreService) wrapPuzzles() error {
+	puzzles, err := sortPuzzles(c.opts.puzzles)
+	if err != nil {
+		return err
+	}
+
+	for _, puzzle := range puzzles {
+		opts, err := c.puzzleOptions(puzzle)
+		if err != nil {
+			return err
+		}
+
+		err = puzzle.Before(opts)
 		if err != nil {
-			plog.Fatalf("Before puzzle %s error: %v", puzzle.Name(), err)
+			c.opts.setPuzzleState(puzzle.Name(), PuzzleFailed, err)
+			return perror.WrapError(500, err, fmt.Sprintf("before puzzle %s", puzzle.Name()))
+		}
+		if opts != c.opts {
+			// keep the handler wrapped by the puzzle for the listener it is routed to
+			l, _ := c.opts.Listener(c.opts.puzzleListeners[puzzle.Name()])
+			l.HttpHandler = opts.HttpHandler
+		}
+
+		var deps []string
+		for _, dep := range puzzleDependencies(puzzle) {
+			if _, ok := c.opts.puzzles[dep]; ok {
+				deps = append(deps, dep)
+			}
 		}
 
 		mf := mountFn{
 			name:   puzzle.Name(),
 			daemon: true,
 			fn: func(ctx context.Context) error {
-				return puzzle.StartPuzzle(ctx, c.options())
+				c.opts.setPuzzleState(puzzle.Name(), PuzzleWaiting, nil)
+				for _, dep := range deps {
+					if err := c.opts.WaitReady(ctx, dep); err != nil {
+						c.opts.setPuzzleState(puzzle.Name(), PuzzleStopped, nil)
+						return err
+					}
+				}
+
+				c.opts.setPuzzleState(puzzle.Name(), PuzzleRunning, nil)
+				if !marksReady(puzzle) {
+					c.opts.MarkReady(puzzle.Name())
+				}
+				if err := puzzle.StartPuzzle(ctx, opts); err != nil {
+					c.opts.setPuzzleState(puzzle.Name(), PuzzleFailed, err)
+					return err
+				}
+
+				select {
+				case <-c.opts.ShuttingDown():
+					c.opts.setPuzzleState(puzzle.Name(), PuzzleStopped, nil)
+				default:
+					c.opts.setPuzzleState(puzzle.Name(), PuzzleFinished, nil)
+				}
+				c.opts.MarkReady(puzzle.Name())
+				return nil
 			},
+			stop:         puzzle.Stop,
+			stopPriority: StopPriorityServer,
+			stopTimeout:  defaultStopTimeout,
+		}
+
+		if p, ok := puzzle.(HasStopPriority); ok {
+			mf.stopPriority = p.StopPriority()
+		}
+		if t, ok := puzzle.(HasStopTimeout); ok {
+			mf.stopTimeout = t.StopTimeout()
 		}
 
 		c.mountFns = append(c.mountFns, mf)
 	}
+
+	return nil
 }
@@ -0,0 +1,202 @@
+package adminpuzzle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/pauth"
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/go-puzzles/puzzles/plog"
+
+	basepuzzle "github.com/go-puzzles/puzzles/cores/puzzles/base"
+)
+
+const PuzzleName = "AdminPuzzle"
+
+type adminPuzzle struct {
+	*basepuzzle.BasePuzzle
+	prefix   string
+	policy   *pauth.Policy
+	insecure bool
+	opts     *cores.Options
+}
+
+type adminPuzzleOption func(ap *adminPuzzle)
+
+// WithAdminPrefix sets the url prefix the admin endpoints are served under, the default is "/admin".
+func WithAdminPrefix(prefix string) adminPuzzleOption {
+	return func(ap *adminPuzzle) {
+		ap.prefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// WithAdminAuth authorizes the requests to the admin endpoints with p, the methods of its options
+// are matched by the url paths, e.g. pauth.WithRoles("/admin/api/cron/*", "admin").
+// The html page and its buttons are plain browser requests: they only carry the cookies and the
+// basic auth credentials of the browser. With an authenticator reading another header, like
+// pauth.APIKey, use the json endpoints or a reverse proxy adding the header.
+func WithAdminAuth(p *pauth.Policy) adminPuzzleOption {
+	return func(ap *adminPuzzle) {
+		ap.policy = p
+	}
+}
+
+// WithAdminInsecure serves the admin endpoints without authentication,
+// only use it when they are not reachable publicly.
+func WithAdminInsecure() adminPuzzleOption {
+	return func(ap *adminPuzzle) {
+		ap.insecure = true
+	}
+}
+
+// WithCoreAdmin serves the state of the puzzles, workers and cron jobs of the service as JSON
+// and as a html page, and allows to trigger, pause and resume cron jobs.
+// The service fails to start unless WithAdminAuth or WithAdminInsecure is given.
+func WithCoreAdmin(opts ...adminPuzzleOption) cores.ServiceOption {
+	return func(o *cores.Options) {
+		ap := &adminPuzzle{
+			BasePuzzle: &basepuzzle.BasePuzzle{
+				PuzzleName: PuzzleName,
+			},
+			prefix: "/admin",
+		}
+
+		for _, opt := range opts {
+			opt(ap)
+		}
+
+		o.RegisterPuzzle(ap)
+		plog.Debugf("Admin enabled.")
+	}
+}
+
+func (ap *adminPuzzle) Before(_ *cores.Options) error {
+	if ap.policy == nil && !ap.insecure {
+		return perror.PackError(500, "admin puzzle needs an auth policy, use WithAdminAuth or WithAdminInsecure")
+	}
+	return nil
+}
+
+func (ap *adminPuzzle) StartPuzzle(ctx context.Context, opt *cores.Options) error {
+	ap.opts = opt
+
+	opt.HttpMux.Handle(fmt.Sprintf("GET %s/{$}", ap.prefix), ap.authorize(ap.servePage))
+	opt.HttpMux.Handle(fmt.Sprintf("GET %s/api/state", ap.prefix), ap.authorize(ap.serveState))
+	opt.HttpMux.Handle(fmt.Sprintf("POST %s/api/cron/{action}", ap.prefix), ap.authorize(sameOrigin(ap.serveCronAction)))
+
+	plog.Debugc(ctx, "AdminPuzzle enabled. URL=%s", fmt.Sprintf("http://%s%s/", opt.ListenerAddr, ap.prefix))
+	return nil
+}
+
+func (ap *adminPuzzle) Stop() error {
+	return nil
+}
+
+type state struct {
+	Service  string             `json:"service"`
+	Puzzles  []cores.PuzzleInfo `json:"puzzles"`
+	Workers  []cores.WorkerInfo `json:"workers"`
+	CronJobs []cores.CronInfo   `json:"cronJobs"`
+}
+
+func (ap *adminPuzzle) state() state {
+	return state{
+		Service:  ap.opts.ServiceName,
+		Puzzles:  ap.opts.Puzzles(),
+		Workers:  ap.opts.Workers(),
+		CronJobs: ap.opts.CronJobs(),
+	}
+}
+
+func (ap *adminPuzzle) authorize(next http.HandlerFunc) http.Handler {
+	if ap.policy == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := ap.policy.Authorize(r.Context(), r.URL.Path, r.Header)
+		if err != nil {
+			code := http.StatusUnauthorized
+			if perror.GetErrorCode(err) == http.StatusForbidden {
+				code = http.StatusForbidden
+			}
+			writeJSON(w, code, map[string]string{"error": err.Error()})
+			return
+		}
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// sameOrigin rejects the cross origin requests sent by browsers, so that another site can not
+// make the browser of an operator post to the admin endpoints. Requests of non browser clients
+// carry neither Sec-Fetch-Site nor Origin and are allowed.
+func sameOrigin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := true
+		switch r.Header.Get("Sec-Fetch-Site") {
+		case "same-origin", "none":
+		case "":
+			if origin := r.Header.Get("Origin"); origin != "" {
+				u, err := url.Parse(origin)
+				allowed = err == nil && u.Host == r.Host
+			}
+		default:
+			allowed = false
+		}
+
+		if !allowed {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross origin request rejected"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (ap *adminPuzzle) serveState(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, ap.state())
+}
+
+func (ap *adminPuzzle) serveCronAction(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+
+	var err error
+	switch action := r.PathValue("action"); action {
+	case "trigger":
+		err = ap.opts.TriggerCron(name)
+	case "pause":
+		err = ap.opts.PauseCron(name)
+	case "resume":
+		err = ap.opts.ResumeCron(name)
+	default:
+		err = perror.PackError(perror.CodeNotFound, fmt.Sprintf("unknown cron action %s", action))
+	}
+
+	if err != nil {
+		code := http.StatusInternalServerError
+		if perror.GetErrorCode(err) == perror.CodeNotFound {
+			code = http.StatusNotFound
+		}
+		writeJSON(w, code, map[string]string{"error": err.Error()})
+		return
+	}
+
+	plog.Infoc(r.Context(), "Admin %s cron job %s", r.PathValue("action"), name)
+
+	// forms posted by the html page are redirected back to it
+	if r.FormValue("redirect") != "" {
+		http.Redirect(w, r, ap.prefix+"/", http.StatusSeeOther)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
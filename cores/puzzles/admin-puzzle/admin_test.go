@@ -0,0 +1,56 @@
+package adminpuzzle_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/cores/coretest"
+	"github.com/go-puzzles/puzzles/pauth"
+	"github.com/stretchr/testify/assert"
+
+	adminpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/admin-puzzle"
+)
+
+func TestAdmin_RequiresAuth(t *testing.T) {
+	srv := cores.NewPuzzleCore(adminpuzzle.WithCoreAdmin())
+	assert.NotNil(t, cores.Start(srv, "127.0.0.1:0"))
+}
+
+func TestAdmin_Auth(t *testing.T) {
+	auth := pauth.APIKey("", map[string]pauth.Principal{"secret": {Subject: "ops"}})
+	s := coretest.Start(t,
+		adminpuzzle.WithCoreAdmin(adminpuzzle.WithAdminAuth(pauth.NewPolicy(auth))),
+		cores.WithCronWorker("@every 1h", func(context.Context) error { return nil }, cores.WithCronName("hourly")),
+	)
+
+	do := func(method, path string, header http.Header) int {
+		req, err := http.NewRequest(method, s.URL(path), strings.NewReader("name=hourly"))
+		if !assert.Nil(t, err) {
+			return 0
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		resp, err := s.HTTP.Do(req)
+		if !assert.Nil(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	key := http.Header{pauth.DefaultAPIKeyHeader: {"secret"}}
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/admin/api/state", nil))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/api/state", key))
+
+	crossSite := http.Header{pauth.DefaultAPIKeyHeader: {"secret"}, "Sec-Fetch-Site": {"cross-site"}}
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/admin/api/cron/pause", crossSite))
+	crossOrigin := http.Header{pauth.DefaultAPIKeyHeader: {"secret"}, "Origin": {"http://evil.example"}}
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/admin/api/cron/pause", crossOrigin))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/admin/api/cron/pause", key))
+}
@@ -0,0 +1,70 @@
+package adminpuzzle
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/go-puzzles/puzzles/plog"
+)
+
+var pageTmpl = template.Must(template.New("admin").Funcs(template.FuncMap{
+	"time": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format(time.DateTime)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.State.Service}} admin</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+form { display: inline; }
+</style>
+</head>
+<body>
+<h1>{{.State.Service}}</h1>
+
+<h2>Puzzles</h2>
+<table>
+<tr><th>Name</th><th>State</th><th>Ready</th><th>Depends on</th><th>Error</th></tr>
+{{range .State.Puzzles}}<tr><td>{{.Name}}</td><td>{{.State}}</td><td>{{.Ready}}</td><td>{{range .DependsOn}}{{.}} {{end}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+
+<h2>Workers</h2>
+<table>
+<tr><th>Name</th><th>Kind</th><th>Restart policy</th><th>Restarts</th><th>Last error</th></tr>
+{{range .State.Workers}}<tr><td>{{.Name}}</td><td>{{.Kind}}</td><td>{{.RestartPolicy}}</td><td>{{.Restarts}}</td><td>{{.LastError}}</td></tr>
+{{end}}</table>
+
+<h2>Cron jobs</h2>
+<table>
+<tr><th>Name</th><th>Spec</th><th>Previous run</th><th>Next run</th><th>Running</th><th>Paused</th><th></th></tr>
+{{range .State.CronJobs}}<tr><td>{{.Name}}</td><td>{{.Spec}}</td><td>{{time .Prev}}</td><td>{{time .Next}}</td><td>{{.Running}}</td><td>{{.Paused}}</td>
+<td>
+{{$name := .Name}}
+<form method="post" action="{{$.Prefix}}/api/cron/trigger"><input type="hidden" name="name" value="{{$name}}"><input type="hidden" name="redirect" value="1"><button>Trigger</button></form>
+{{if .Paused}}<form method="post" action="{{$.Prefix}}/api/cron/resume"><input type="hidden" name="name" value="{{$name}}"><input type="hidden" name="redirect" value="1"><button>Resume</button></form>
+{{else}}<form method="post" action="{{$.Prefix}}/api/cron/pause"><input type="hidden" name="name" value="{{$name}}"><input type="hidden" name="redirect" value="1"><button>Pause</button></form>{{end}}
+</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// servePage renders the admin page, its buttons post plain html forms to the cron endpoints
+func (ap *adminPuzzle) servePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := pageTmpl.Execute(w, map[string]any{
+		"Prefix": ap.prefix,
+		"State":  ap.state(),
+	})
+	if err != nil {
+		plog.Errorc(r.Context(), "render admin page error: %v", err)
+	}
+}
@@ -96,12 +96,22 @@ func (cp *consulPuzzle) StartPuzzle(ctx context.Context, opt *cores.Options) err
 	}
 
 	plog.Infoc(ctx, logText, logArgs...)
+	opt.MarkReady(cp.Name())
 
 	<-ctx.Done()
 
 	return nil
 }
 
+// StopPriority makes the service deregister from consul before the servers stop accepting requests
+func (cp *consulPuzzle) MarksReady() bool {
+	return true
+}
+
+func (cp *consulPuzzle) StopPriority() int {
+	return cores.StopPriorityDiscovery
+}
+
 func (cp *consulPuzzle) Stop() error {
 	discover.GetServiceFinder().Close()
 	return nil
@@ -0,0 +1,195 @@
+package gatewaypuzzle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/perror/pstatus"
+	"github.com/go-puzzles/puzzles/pgin"
+	"github.com/go-puzzles/puzzles/plog"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	basepuzzle "github.com/go-puzzles/puzzles/cores/puzzles/base"
+	grpcpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpc-puzzle"
+)
+
+const PuzzleName = "GatewayPuzzle"
+
+const defaultPrefix = "/api"
+
+// RegisterFunc registers the handlers of a service on the gateway, like the generated RegisterXXXHandler
+type RegisterFunc func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+type gatewayPuzzle struct {
+	*basepuzzle.BasePuzzle
+
+	prefix       string
+	registers    []RegisterFunc
+	muxOpts      []runtime.ServeMuxOption
+	dialOpts     []grpc.DialOption
+	marshaler    *runtime.JSONPb
+	grpcSelfConn *grpc.ClientConn
+}
+
+type gatewayOption func(gp *gatewayPuzzle)
+
+// WithGatewayPrefix sets the path the gateway is mounted under, the default is /api
+func WithGatewayPrefix(prefix string) gatewayOption {
+	return func(gp *gatewayPuzzle) {
+		gp.prefix = "/" + strings.Trim(prefix, "/")
+	}
+}
+
+// WithGatewayMuxOptions adds options to the runtime.ServeMux of the gateway
+func WithGatewayMuxOptions(opts ...runtime.ServeMuxOption) gatewayOption {
+	return func(gp *gatewayPuzzle) {
+		gp.muxOpts = append(gp.muxOpts, opts...)
+	}
+}
+
+// WithGatewayDialOptions adds options to the connection of the gateway to the grpc server
+func WithGatewayDialOptions(opts ...grpc.DialOption) gatewayOption {
+	return func(gp *gatewayPuzzle) {
+		gp.dialOpts = append(gp.dialOpts, opts...)
+	}
+}
+
+func getGatewayPuzzle(o *cores.Options) *gatewayPuzzle {
+	if p, ok := o.GetPuzzle(PuzzleName); ok {
+		if gp, ok := p.(*gatewayPuzzle); ok {
+			return gp
+		}
+	}
+
+	gp := &gatewayPuzzle{
+		BasePuzzle: &basepuzzle.BasePuzzle{
+			PuzzleName: PuzzleName,
+		},
+		prefix: defaultPrefix,
+		marshaler: &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		},
+	}
+	o.RegisterPuzzle(gp)
+	return gp
+}
+
+// WithCoreGateway serves the grpc services registered by register as REST through grpc-gateway.
+// The responses are wrapped in the pgin.Ret envelope.
+func WithCoreGateway(register RegisterFunc, opts ...gatewayOption) cores.ServiceOption {
+	return func(o *cores.Options) {
+		gp := getGatewayPuzzle(o)
+		gp.registers = append(gp.registers, register)
+
+		for _, opt := range opts {
+			opt(gp)
+		}
+	}
+}
+
+func (gp *gatewayPuzzle) Before(opt *cores.Options) error {
+	conn, err := grpcpuzzle.DialSelf(opt, gp.dialOpts...)
+	if err != nil {
+		return errors.Wrap(err, "prepareSelfConnect")
+	}
+	gp.grpcSelfConn = conn
+
+	return nil
+}
+
+func (gp *gatewayPuzzle) DependsOn() []string {
+	return []string{grpcpuzzle.PuzzleName}
+}
+
+func (gp *gatewayPuzzle) StartPuzzle(ctx context.Context, opt *cores.Options) error {
+	muxOpts := append([]runtime.ServeMuxOption{
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, gp.marshaler),
+		runtime.WithForwardResponseRewriter(gp.rewriteResponse),
+		runtime.WithErrorHandler(errorHandler),
+	}, gp.muxOpts...)
+	mux := runtime.NewServeMux(muxOpts...)
+
+	for _, register := range gp.registers {
+		if err := register(ctx, mux, gp.grpcSelfConn); err != nil {
+			return errors.Wrap(err, "register gateway handler")
+		}
+	}
+
+	opt.HttpMux.Handle(gp.prefix+"/", http.StripPrefix(gp.prefix, mux))
+
+	plog.Debugc(ctx, "GatewayPuzzle enabled. URL=%s", fmt.Sprintf("http://%s%s/", opt.ListenerAddr, gp.prefix))
+	return nil
+}
+
+func (gp *gatewayPuzzle) Stop() error {
+	defer plog.Debugf("gateway puzzle stopped...")
+	return gp.grpcSelfConn.Close()
+}
+
+// rewriteResponse wraps the responses in the pgin.Ret envelope
+func (gp *gatewayPuzzle) rewriteResponse(_ context.Context, resp proto.Message) (any, error) {
+	data, err := gp.marshaler.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return pgin.SuccessRet(json.RawMessage(data)), nil
+}
+
+// errorHandler writes the error in the pgin.Ret envelope with the code of the perror returned
+// by the grpc handler, which is also the http status when it is a valid one.
+func errorHandler(ctx context.Context, _ *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	httpCode := runtime.HTTPStatusFromCode(status.Code(err))
+	var customStatus *runtime.HTTPStatusError
+	if errors.As(err, &customStatus) {
+		httpCode = customStatus.HTTPStatus
+		err = customStatus.Err
+	}
+	st := status.Convert(err)
+	errCode := httpCode
+	if customStatus == nil {
+		errCode = pstatus.CodeOf(st)
+		if errCode >= 100 && errCode < 600 && http.StatusText(errCode) != "" {
+			httpCode = errCode
+		}
+	}
+
+	if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+		for k, vs := range md.HeaderMD {
+			for _, v := range vs {
+				w.Header().Add(runtime.MetadataHeaderPrefix+k, v)
+			}
+		}
+	}
+
+	buf, merr := marshaler.Marshal(pgin.ErrorRet(errCode, st.Message()))
+	if merr != nil {
+		plog.Errorc(ctx, "marshal gateway error: %v", merr)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Del("Trailer")
+	w.Header().Del("Transfer-Encoding")
+	w.Header().Set("Content-Type", marshaler.ContentType(nil))
+	w.WriteHeader(httpCode)
+	if _, err := w.Write(buf); err != nil {
+		plog.Errorc(ctx, "write gateway error response: %v", err)
+	}
+
+	// client errors are part of the normal traffic, only the server errors are worth an error log
+	if httpCode >= http.StatusInternalServerError {
+		plog.Errorc(ctx, "handle request: %s error: %v", r.URL.Path, err)
+	} else {
+		plog.Debugc(ctx, "handle request: %s error: %v", r.URL.Path, err)
+	}
+}
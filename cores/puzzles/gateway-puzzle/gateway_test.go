@@ -0,0 +1,173 @@
+package gatewaypuzzle_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/cores/coretest"
+	"github.com/go-puzzles/puzzles/example/cores-with-grpc/examplepb"
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	gatewaypuzzle "github.com/go-puzzles/puzzles/cores/puzzles/gateway-puzzle"
+	grpcpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpc-puzzle"
+)
+
+type helloService struct {
+	examplepb.UnimplementedExampleHelloServiceServer
+}
+
+func (s *helloService) SayHello(_ context.Context, in *examplepb.HelloRequest) (*examplepb.HelloResponse, error) {
+	switch in.Name {
+	case "missing":
+		return nil, perror.PackError(perror.CodeNotFound, "user not found")
+	case "teapot":
+		return nil, perror.PackError(http.StatusTeapot, "short and stout")
+	case "denied":
+		return nil, status.Error(codes.PermissionDenied, "denied")
+	case "panic":
+		panic("boom")
+	}
+	return &examplepb.HelloResponse{Message: "Hello " + in.Name}, nil
+}
+
+func TestGateway(t *testing.T) {
+	s := coretest.Start(t,
+		grpcpuzzle.WithCoreGrpcPuzzle(func(srv *grpc.Server) {
+			examplepb.RegisterExampleHelloServiceServer(srv, &helloService{})
+		}),
+		gatewaypuzzle.WithCoreGateway(examplepb.RegisterExampleHelloServiceHandler, gatewaypuzzle.WithGatewayPrefix("/rest")),
+	)
+
+	for _, tc := range []struct {
+		name    string
+		status  int
+		code    int
+		data    string
+		message string
+	}{
+		{name: "puzzle", status: http.StatusOK, code: http.StatusOK, data: `{"message":"Hello puzzle"}`, message: "success"},
+		{name: "missing", status: http.StatusNotFound, code: perror.CodeNotFound, message: "user not found"},
+		{name: "teapot", status: http.StatusTeapot, code: http.StatusTeapot, message: "short and stout"},
+		{name: "denied", status: http.StatusForbidden, code: http.StatusForbidden, message: "denied"},
+		{name: "panic", status: http.StatusInternalServerError, code: http.StatusInternalServerError, message: "panic in " + examplepb.ExampleHelloService_SayHello_FullMethodName},
+	} {
+		resp, err := s.HTTP.Post(s.URL("/rest/hello"), "application/json", strings.NewReader(`{"name":"`+tc.name+`"}`))
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		var ret struct {
+			Code    int             `json:"code"`
+			Data    json.RawMessage `json:"data"`
+			Message string          `json:"message"`
+		}
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&ret))
+		resp.Body.Close()
+
+		assert.Equal(t, tc.status, resp.StatusCode, tc.name)
+		assert.Equal(t, tc.code, ret.Code, tc.name)
+		assert.Equal(t, tc.message, ret.Message, tc.name)
+		if tc.data != "" {
+			assert.JSONEq(t, tc.data, string(ret.Data), tc.name)
+		}
+	}
+}
+
+// issueCert signs a certificate for usage with parent, the certificate is self-signed without parent
+func issueCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, usage x509.ExtKeyUsage) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage = nil
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestGateway_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// the server certificate is only valid for server auth, as usual
+	ca, caKey, caPem, _ := issueCert(t, nil, nil, 0)
+	_, _, serverPem, serverKey := issueCert(t, ca, caKey, x509.ExtKeyUsageServerAuth)
+	_, _, clientPem, clientKey := issueCert(t, ca, caKey, x509.ExtKeyUsageClientAuth)
+
+	s := coretest.Start(t,
+		cores.WithTLS(write("server.pem", serverPem), write("server.key", serverKey), cores.WithClientCA(write("ca.pem", caPem))),
+		grpcpuzzle.WithCoreGrpcPuzzle(func(srv *grpc.Server) {
+			examplepb.RegisterExampleHelloServiceServer(srv, &helloService{})
+		}),
+		gatewaypuzzle.WithCoreGateway(examplepb.RegisterExampleHelloServiceHandler, gatewaypuzzle.WithGatewayPrefix("/rest")),
+	)
+
+	clientCert, err := tls.X509KeyPair(clientPem, clientKey)
+	if !assert.Nil(t, err) {
+		return
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}},
+	}}
+
+	resp, err := client.Post("https://"+s.Addr+"/rest/hello", "application/json", strings.NewReader(`{"name":"puzzle"}`))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Contains(t, string(body), "Hello puzzle")
+}
@@ -0,0 +1,60 @@
+package grpcpuzzle
+
+import (
+	"context"
+
+	"github.com/go-puzzles/puzzles/pauth"
+	"github.com/go-puzzles/puzzles/perror/pstatus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithAuth authenticates the callers of the rpcs with auth, the principal is read with pauth.FromContext.
+// The methods are matched by their full name, like "/package.Service/Method".
+func WithAuth(auth pauth.Authenticator, opts ...pauth.Option) grpcPuzzlesOption {
+	return WithAuthPolicy(pauth.NewPolicy(auth, opts...))
+}
+
+// WithAuthPolicy authenticates the callers of the rpcs and checks their roles with p
+func WithAuthPolicy(p *pauth.Policy) grpcPuzzlesOption {
+	return func(gp *grpcPuzzles) {
+		gp.unaryInterceptors = append(gp.unaryInterceptors, unaryServerAuthInterceptor(p))
+		gp.streamInterceptors = append(gp.streamInterceptors, streamServerAuthInterceptor(p))
+	}
+}
+
+func unaryServerAuthInterceptor(p *pauth.Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authorize(ctx, p, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamServerAuthInterceptor(p *pauth.Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), p, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, newInjectServerStream(ctx, ss))
+	}
+}
+
+func authorize(ctx context.Context, p *pauth.Policy, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx, err := p.Authorize(ctx, method, mdHeader(md))
+	return ctx, pstatus.ToError(err)
+}
+
+// mdHeader reads the incoming metadata as headers
+type mdHeader metadata.MD
+
+func (h mdHeader) Get(key string) string {
+	if vals := metadata.MD(h).Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package grpcpuzzle_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-puzzles/puzzles/cores/coretest"
+	"github.com/go-puzzles/puzzles/example/cores-with-grpc/examplepb"
+	"github.com/go-puzzles/puzzles/pauth"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	grpcpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpc-puzzle"
+)
+
+type whoamiService struct {
+	examplepb.UnimplementedExampleHelloServiceServer
+}
+
+func (s *whoamiService) SayHello(ctx context.Context, _ *examplepb.HelloRequest) (*examplepb.HelloResponse, error) {
+	p, _ := pauth.FromContext(ctx)
+	return &examplepb.HelloResponse{Message: p.Subject}, nil
+}
+
+func TestAuth(t *testing.T) {
+	keys := map[string]pauth.Principal{
+		"admin-key":  {Subject: "alice", Roles: []string{"admin"}},
+		"reader-key": {Subject: "bob"},
+	}
+	s := coretest.Start(t,
+		grpcpuzzle.WithCoreGrpcPuzzle(func(srv *grpc.Server) {
+			examplepb.RegisterExampleHelloServiceServer(srv, &whoamiService{})
+		}, grpcpuzzle.WithAuth(pauth.APIKey("", keys),
+			pauth.WithRoles(examplepb.ExampleHelloService_SayHello_FullMethodName, "admin"),
+		)),
+	)
+
+	client := examplepb.NewExampleHelloServiceClient(s.Grpc)
+	hello := func(key string) (string, error) {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, pauth.DefaultAPIKeyHeader, key)
+		}
+		resp, err := client.SayHello(ctx, &examplepb.HelloRequest{})
+		return resp.GetMessage(), err
+	}
+
+	subject, err := hello("admin-key")
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", subject)
+
+	_, err = hello("reader-key")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = hello("")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
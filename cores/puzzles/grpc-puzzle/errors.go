@@ -0,0 +1,49 @@
+package grpcpuzzle
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/go-puzzles/puzzles/perror/pstatus"
+	"github.com/go-puzzles/puzzles/plog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unaryServerErrorInterceptor converts the perror returned by the handlers to grpc statuses
+func unaryServerErrorInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, pstatus.ToError(err)
+}
+
+func streamServerErrorInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return pstatus.ToError(handler(srv, ss))
+}
+
+func recoverError(ctx context.Context, method string, r any) error {
+	plog.Errorc(ctx, "grpc method %s panic: %v\n%s", method, r, debug.Stack())
+	return status.Error(codes.Internal, fmt.Sprintf("panic in %s", method))
+}
+
+// unaryServerRecoveryInterceptor turns the panics of the handlers into Internal errors
+func unaryServerRecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverError(ctx, info.FullMethod, r)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func streamServerRecoveryInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverError(ss.Context(), info.FullMethod, r)
+		}
+	}()
+
+	return handler(srv, ss)
+}
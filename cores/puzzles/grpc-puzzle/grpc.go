@@ -2,38 +2,32 @@ package grpcpuzzle
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/cores/health"
+	"github.com/go-puzzles/puzzles/perror"
 	"github.com/go-puzzles/puzzles/plog"
+	"github.com/go-puzzles/puzzles/plog/rpclog"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
 
 	basepuzzle "github.com/go-puzzles/puzzles/cores/puzzles/base"
+	grpchealth "google.golang.org/grpc/health"
 )
 
-var (
-	grpcLis  net.Listener
-	grpcInit = make(chan struct{}, 1)
+const PuzzleName = "GrpcHandler"
 
-	gp = &grpcPuzzles{
-		BasePuzzle: &basepuzzle.BasePuzzle{
-			PuzzleName: "GrpcHandler",
-		},
-		opts: make([]grpc.ServerOption, 0),
-		unaryInterceptors: []grpc.UnaryServerInterceptor{
-			unaryServerLoggerInterceptor,
-		},
-		streamInterceptors: []grpc.StreamServerInterceptor{
-			StreamServerLoggerInterceptor,
-		},
-	}
+const (
+	defaultGracefulStopTimeout = 5 * time.Second
+	// forceStopMargin leaves the time to close the remaining streams once the graceful stop timed out
+	forceStopMargin = time.Second
 )
 
-func IsGrpcServerInit() <-chan struct{} {
-	return grpcInit
-}
-
 type grpcPuzzles struct {
 	*basepuzzle.BasePuzzle
 	grpcSrv            *grpc.Server
@@ -41,10 +35,62 @@ type grpcPuzzles struct {
 	unaryInterceptors  []grpc.UnaryServerInterceptor
 	streamInterceptors []grpc.StreamServerInterceptor
 	opts               []grpc.ServerOption
+
+	lis net.Listener
+	// self serves the in process connections of DialSelf
+	self *bufconn.Listener
+
+	healthSrv      *grpchealth.Server
+	healthCheckers map[string][]health.CheckFunc
+	healthInterval time.Duration
+
+	gracefulStopTimeout time.Duration
+	logConfig           *rpclog.Config
+	// streams counts the rpcs being handled
+	streams atomic.Int64
+	stopped atomic.Bool
 }
 
 type grpcPuzzlesOption func(gp *grpcPuzzles)
 
+func newGrpcPuzzles() *grpcPuzzles {
+	logConfig := rpclog.NewConfig()
+	return &grpcPuzzles{
+		BasePuzzle: &basepuzzle.BasePuzzle{
+			PuzzleName: PuzzleName,
+		},
+		opts: make([]grpc.ServerOption, 0),
+		unaryInterceptors: []grpc.UnaryServerInterceptor{
+			unaryServerLoggerInterceptor(logConfig),
+			unaryServerErrorInterceptor,
+			unaryServerRecoveryInterceptor,
+		},
+		streamInterceptors: []grpc.StreamServerInterceptor{
+			streamServerLoggerInterceptor(logConfig),
+			streamServerErrorInterceptor,
+			streamServerRecoveryInterceptor,
+		},
+		self:                bufconn.Listen(selfBufSize),
+		healthCheckers:      make(map[string][]health.CheckFunc),
+		gracefulStopTimeout: defaultGracefulStopTimeout,
+		logConfig:           logConfig,
+	}
+}
+
+// getGrpcPuzzles returns the grpc puzzle of the cores service, creating and registering it if necessary.
+func getGrpcPuzzles(o *cores.Options) *grpcPuzzles {
+	if p, ok := o.GetPuzzle(PuzzleName); ok {
+		if gp, ok := p.(*grpcPuzzles); ok {
+			return gp
+		}
+	}
+
+	gp := newGrpcPuzzles()
+	o.Tags = append(o.Tags, "grpc")
+	o.RegisterPuzzle(gp)
+	return gp
+}
+
 func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpcPuzzlesOption {
 	return func(gp *grpcPuzzles) {
 		gp.unaryInterceptors = append(gp.unaryInterceptors, interceptors...)
@@ -65,21 +111,40 @@ func WithServerOptions(opts ...grpc.ServerOption) grpcPuzzlesOption {
 	}
 }
 
+// WithGracefulStopTimeout bounds the time given to the in-flight rpcs to finish on stop,
+// the streams still open after it are closed.
+func WithGracefulStopTimeout(timeout time.Duration) grpcPuzzlesOption {
+	return func(gp *grpcPuzzles) {
+		gp.gracefulStopTimeout = timeout
+	}
+}
+
 func WithCoreGrpcPuzzle(grpcSrv func(srv *grpc.Server), opts ...grpcPuzzlesOption) cores.ServiceOption {
 	return func(o *cores.Options) {
+		gp := getGrpcPuzzles(o)
 		gp.grpcServersFunc = append(gp.grpcServersFunc, grpcSrv)
-		o.Tags = append(o.Tags, "grpc")
 
 		for _, opt := range opts {
 			opt(gp)
 		}
 
-		o.RegisterPuzzle(gp)
 		plog.Debugf("Grpc server enabled.")
 	}
 }
 
 func (g *grpcPuzzles) Before(opt *cores.Options) error {
+	// claim the http2 connections before the listener accepts any
+	g.lis = opt.GrpcListener()
+
+	if opt.Metrics != nil {
+		m := newGrpcMetrics(opt.Metrics)
+		g.unaryInterceptors = append([]grpc.UnaryServerInterceptor{m.unaryInterceptor}, g.unaryInterceptors...)
+		g.streamInterceptors = append([]grpc.StreamServerInterceptor{m.streamInterceptor}, g.streamInterceptors...)
+	}
+
+	g.unaryInterceptors = append([]grpc.UnaryServerInterceptor{g.unaryStreamsInterceptor}, g.unaryInterceptors...)
+	g.streamInterceptors = append([]grpc.StreamServerInterceptor{g.streamStreamsInterceptor}, g.streamInterceptors...)
+
 	if len(g.unaryInterceptors) != 0 {
 		g.opts = append(g.opts, grpc.ChainUnaryInterceptor(g.unaryInterceptors...))
 	}
@@ -94,18 +159,68 @@ func (g *grpcPuzzles) Before(opt *cores.Options) error {
 	}
 
 	reflection.Register(g.grpcSrv)
+	g.registerHealth()
 
 	return nil
 }
 
 func (g *grpcPuzzles) StartPuzzle(ctx context.Context, opt *cores.Options) error {
-	grpcInit <- struct{}{}
+	go g.watchHealth(ctx, opt)
+	opt.MarkReady(g.Name())
+
+	go g.grpcSrv.Serve(g.self)
+	err := g.grpcSrv.Serve(g.lis)
+	if g.stopped.Load() {
+		// the listener may be closed by the cmux before the server drained
+		return nil
+	}
+	return err
+}
 
-	return g.grpcSrv.Serve(opt.GrpcListener())
+func (g *grpcPuzzles) MarksReady() bool {
+	return true
 }
 
+func (g *grpcPuzzles) StopTimeout() time.Duration {
+	return g.gracefulStopTimeout + forceStopMargin
+}
+
+// Stop sends GOAWAY to the clients and waits for the in-flight rpcs to finish,
+// the streams still open after the graceful stop timeout are closed.
 func (g *grpcPuzzles) Stop() error {
 	defer plog.Debugf("grpc puzzle stopped...")
+	g.stopped.Store(true)
+	g.healthSrv.Shutdown()
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		g.grpcSrv.GracefulStop()
+	}()
+
+	timer := time.NewTimer(g.gracefulStopTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+		return nil
+	case <-timer.C:
+	}
+
+	open := g.streams.Load()
 	g.grpcSrv.Stop()
-	return grpcLis.Close()
+	<-drained
+	return perror.PackError(500, fmt.Sprintf("grpc graceful stop timed out after %v, %d streams forced to close", g.gracefulStopTimeout, open))
+}
+
+func (g *grpcPuzzles) unaryStreamsInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	g.streams.Add(1)
+	defer g.streams.Add(-1)
+	return handler(ctx, req)
+}
+
+func (g *grpcPuzzles) streamStreamsInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	g.streams.Add(1)
+	defer g.streams.Add(-1)
+	return handler(srv, ss)
 }
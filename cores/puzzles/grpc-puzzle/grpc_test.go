@@ -0,0 +1,111 @@
+package grpcpuzzle_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/cores/coretest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	grpcpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpc-puzzle"
+)
+
+func TestGracefulStopTimeout(t *testing.T) {
+	s := coretest.Start(t,
+		grpcpuzzle.WithCoreGrpcPuzzle(func(*grpc.Server) {}, grpcpuzzle.WithGracefulStopTimeout(200*time.Millisecond)),
+	)
+
+	// a health watch stays open until the server closes it
+	stream, err := grpc_health_v1.NewHealthClient(s.Grpc).Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = stream.Recv()
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	assert.Nil(t, s.Core.Shutdown(ctx))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	assert.NotNil(t, err)
+}
+
+func TestHealthCheckerTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+
+	s := coretest.Start(t,
+		grpcpuzzle.WithCoreGrpcPuzzle(func(*grpc.Server) {},
+			grpcpuzzle.WithHealthCheckInterval(100*time.Millisecond),
+			// ignores its context, it must neither block the round nor the other services
+			grpcpuzzle.WithHealthChecker("db", func(context.Context) error {
+				<-hang
+				return nil
+			}),
+			grpcpuzzle.WithHealthChecker("cache", func(context.Context) error { return nil }),
+		),
+	)
+
+	client := grpc_health_v1.NewHealthClient(s.Grpc)
+	status := func(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		if err != nil {
+			return grpc_health_v1.HealthCheckResponse_UNKNOWN
+		}
+		return resp.GetStatus()
+	}
+
+	assert.Eventually(t, func() bool {
+		return status("db") == grpc_health_v1.HealthCheckResponse_NOT_SERVING &&
+			status("cache") == grpc_health_v1.HealthCheckResponse_SERVING &&
+			status("") == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestGrpcAndH2C(t *testing.T) {
+	s := coretest.Start(t,
+		grpcpuzzle.WithCoreGrpcPuzzle(func(*grpc.Server) {}),
+		cores.WithListener("h2c", "tcp", "127.0.0.1:0"),
+	)
+	l, _ := s.Options.Listener("h2c")
+	l.HttpMux.HandleFunc("/proto", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	_, err := grpc_health_v1.NewHealthClient(s.Grpc).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.Nil(t, err)
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(fmt.Sprintf("http://%s/proto", l.Addr))
+		if !assert.Nil(t, err) {
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "HTTP/2.0", string(body))
+	}
+
+	// the h2c connections of the main listener reach the grpc server
+	resp, err := client.Get(fmt.Sprintf("http://%s/proto", s.Addr))
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+		assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+	}
+}
@@ -0,0 +1,154 @@
+package grpcpuzzle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/cores/health"
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/go-puzzles/puzzles/plog"
+
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const defaultHealthCheckInterval = 5 * time.Second
+
+// WithHealthChecker makes the health status of service derived from fn. The service
+// may be a registered grpc service or any custom name like "db", an empty name only
+// affects the overall status. Every failing service makes the overall status NOT_SERVING.
+func WithHealthChecker(service string, fn health.CheckFunc) grpcPuzzlesOption {
+	return func(gp *grpcPuzzles) {
+		gp.healthCheckers[service] = append(gp.healthCheckers[service], fn)
+	}
+}
+
+// WithHealthCheckInterval sets how often the health checkers are evaluated.
+func WithHealthCheckInterval(interval time.Duration) grpcPuzzlesOption {
+	return func(gp *grpcPuzzles) {
+		gp.healthInterval = interval
+	}
+}
+
+func (g *grpcPuzzles) registerHealth() {
+	g.healthSrv = grpchealth.NewServer()
+	healthpb.RegisterHealthServer(g.grpcSrv, g.healthSrv)
+}
+
+// watchHealth updates the serving status of every registered service until ctx is done
+// and switches them to NOT_SERVING as soon as the service starts shutting down.
+func (g *grpcPuzzles) watchHealth(ctx context.Context, opt *cores.Options) {
+	interval := g.healthIntervalOrDefault()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		g.updateHealth(ctx, opt.Health)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-opt.ShuttingDown():
+			g.healthSrv.Shutdown()
+			plog.Debugc(ctx, "Grpc health status switched to NOT_SERVING")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *grpcPuzzles) updateHealth(ctx context.Context, registry *health.Registry) {
+	overall := registry.Check(ctx, health.Readiness).Healthy()
+
+	services := make(map[string]struct{})
+	for service := range g.grpcSrv.GetServiceInfo() {
+		services[service] = struct{}{}
+	}
+	for service := range g.healthCheckers {
+		services[service] = struct{}{}
+	}
+
+	// every check runs concurrently and is bounded by the check interval, so that a
+	// hanging checker neither delays the other services nor the next round
+	timeout := g.healthIntervalOrDefault()
+	results := make(map[string]bool, len(services))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for service := range services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			healthy := runCheckers(ctx, timeout, g.healthCheckers[service])
+			mu.Lock()
+			results[service] = healthy
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for service, healthy := range results {
+		if service != "" {
+			g.healthSrv.SetServingStatus(service, servingStatus(healthy))
+		}
+		overall = overall && healthy
+	}
+	g.healthSrv.SetServingStatus("", servingStatus(overall))
+}
+
+func (g *grpcPuzzles) healthIntervalOrDefault() time.Duration {
+	if g.healthInterval <= 0 {
+		return defaultHealthCheckInterval
+	}
+	return g.healthInterval
+}
+
+func runCheckers(ctx context.Context, timeout time.Duration, checkers []health.CheckFunc) bool {
+	errs := make(chan error, len(checkers))
+	for _, fn := range checkers {
+		go func() {
+			errs <- runChecker(ctx, timeout, fn)
+		}()
+	}
+
+	healthy := true
+	for range checkers {
+		if err := <-errs; err != nil {
+			plog.Warnc(ctx, "Grpc health check failed: %v", err)
+			healthy = false
+		}
+	}
+	return healthy
+}
+
+// runChecker returns once the timeout expires even if fn does not honour its context
+func runChecker(ctx context.Context, timeout time.Duration, fn health.CheckFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return perror.WrapError(perror.CodeUnknown, ctx.Err(), "health check timeout")
+	}
+}
+
+func servingStatus(healthy bool) healthpb.HealthCheckResponse_ServingStatus {
+	if healthy {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+func isHealthMethod(fullMethod string) bool {
+	return fullMethod == healthpb.Health_Check_FullMethodName || fullMethod == healthpb.Health_Watch_FullMethodName
+}
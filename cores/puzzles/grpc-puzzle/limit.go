@@ -0,0 +1,75 @@
+package grpcpuzzle
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/go-puzzles/puzzles/plimit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// WithLimits rejects with ResourceExhausted the rpcs over the limits, see plimit.
+// The methods are limited by their full name, like "/package.Service/Method".
+// The health and reflection services are not limited, see plimit.WithExempt.
+func WithLimits(opts ...plimit.Option) grpcPuzzlesOption {
+	return WithLimiter(plimit.New(opts...))
+}
+
+// WithLimiter rejects with ResourceExhausted the rpcs over the limits of l
+func WithLimiter(l *plimit.Limiter) grpcPuzzlesOption {
+	return func(gp *grpcPuzzles) {
+		gp.unaryInterceptors = append(gp.unaryInterceptors, unaryServerLimitInterceptor(l))
+		gp.streamInterceptors = append(gp.streamInterceptors, streamServerLimitInterceptor(l))
+	}
+}
+
+func unaryServerLimitInterceptor(l *plimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		release, err := acquire(ctx, l, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		return handler(ctx, req)
+	}
+}
+
+func streamServerLimitInterceptor(l *plimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := acquire(ss.Context(), l, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return handler(srv, ss)
+	}
+}
+
+func acquire(ctx context.Context, l *plimit.Limiter, method string) (func(), error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	release, err := l.Acquire(method, l.Caller(ctx, mdHeader(md)))
+	if err == nil {
+		return release, nil
+	}
+
+	var le *plimit.Error
+	if !errors.As(err, &le) {
+		return nil, err
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(plimit.RetryAfterKey, strconv.Itoa(le.RetryAfterSeconds())))
+
+	st := status.New(codes.ResourceExhausted, le.Error())
+	if withDetails, derr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(le.RetryAfter)}); derr == nil {
+		st = withDetails
+	}
+	return nil, st.Err()
+}
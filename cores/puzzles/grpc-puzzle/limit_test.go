@@ -0,0 +1,72 @@
+package grpcpuzzle_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-puzzles/puzzles/cores/coretest"
+	"github.com/go-puzzles/puzzles/example/cores-with-grpc/examplepb"
+	"github.com/go-puzzles/puzzles/plimit"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	grpcpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpc-puzzle"
+)
+
+type helloService struct {
+	examplepb.UnimplementedExampleHelloServiceServer
+}
+
+func (s *helloService) SayHello(_ context.Context, in *examplepb.HelloRequest) (*examplepb.HelloResponse, error) {
+	return &examplepb.HelloResponse{Message: "Hello " + in.Name}, nil
+}
+
+func startLimited(t *testing.T, opts ...plimit.Option) *coretest.Server {
+	return coretest.Start(t,
+		grpcpuzzle.WithCoreGrpcPuzzle(func(srv *grpc.Server) {
+			examplepb.RegisterExampleHelloServiceServer(srv, &helloService{})
+		}, grpcpuzzle.WithLimits(opts...)),
+	)
+}
+
+func TestLimits(t *testing.T) {
+	s := startLimited(t, plimit.WithCaller("x-caller", plimit.Limit{Rate: 1, Burst: 1}))
+
+	client := examplepb.NewExampleHelloServiceClient(s.Grpc)
+	hello := func(caller string) (metadata.MD, error) {
+		var header metadata.MD
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-caller", caller)
+		_, err := client.SayHello(ctx, &examplepb.HelloRequest{Name: caller}, grpc.Header(&header))
+		return header, err
+	}
+
+	_, err := hello("alice")
+	assert.Nil(t, err)
+
+	header, err := hello("alice")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"1"}, header.Get(plimit.RetryAfterKey))
+
+	_, err = hello("bob")
+	assert.Nil(t, err)
+}
+
+func TestLimits_HealthExempt(t *testing.T) {
+	s := startLimited(t, plimit.WithGlobal(plimit.Limit{Rate: 1, Burst: 1}))
+
+	client := examplepb.NewExampleHelloServiceClient(s.Grpc)
+	_, err := client.SayHello(context.Background(), &examplepb.HelloRequest{Name: "alice"})
+	assert.Nil(t, err)
+	_, err = client.SayHello(context.Background(), &examplepb.HelloRequest{Name: "alice"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// the probes keep working while the limiter is full
+	for i := 0; i < 3; i++ {
+		_, err = grpc_health_v1.NewHealthClient(s.Grpc).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Nil(t, err)
+	}
+}
@@ -13,48 +13,90 @@ import (
 	"strings"
 
 	"github.com/go-puzzles/puzzles/plog"
+	"github.com/go-puzzles/puzzles/plog/rpclog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
 )
 
-func unaryServerLoggerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if strings.HasPrefix(info.FullMethod, "/grpc.reflection") {
-		return handler(ctx, req)
+var defaultLogConfig = rpclog.NewConfig()
+
+// WithLogOptions configures the logs of the handled rpcs, see rpclog
+func WithLogOptions(opts ...rpclog.Option) grpcPuzzlesOption {
+	return func(gp *grpcPuzzles) {
+		for _, opt := range opts {
+			opt(gp.logConfig)
+		}
 	}
+}
 
-	prefix := strings.TrimPrefix(info.FullMethod, "/")
-	ctx = plog.With(ctx, prefix)
+func skipLog(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.reflection") || isHealthMethod(fullMethod)
+}
 
-	td := plog.TimeFuncDuration()
-	ret, err := handler(ctx, req)
-	duration := td()
-	if err != nil {
-		plog.Debugc(ctx, "Failed to handle method %s handle_time=%s handle_err=%s", info.FullMethod, duration, err)
-	} else {
-		plog.Debugc(ctx, "Succeed to handle method %s handle_time=%s", info.FullMethod, duration)
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
 	}
+	return ""
+}
+
+func unaryServerLoggerInterceptor(conf *rpclog.Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if skipLog(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		prefix := strings.TrimPrefix(info.FullMethod, "/")
+		ctx = plog.With(ctx, prefix)
+
+		td := plog.TimeFuncDuration()
+		ret, err := handler(ctx, req)
+		duration := td()
+		if !conf.Enabled(duration) {
+			return ret, err
+		}
 
-	return ret, err
+		conf.Log(ctx, "handle", rpclog.Call{
+			Method:   info.FullMethod,
+			Duration: duration,
+			Err:      err,
+			Peer:     peerAddr(ctx),
+			Req:      req,
+			Resp:     ret,
+		})
+		return ret, err
+	}
 }
 
 func StreamServerLoggerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-	if strings.HasPrefix(info.FullMethod, "/grpc.reflection") {
-		return handler(srv, ss)
-	}
+	return streamServerLoggerInterceptor(defaultLogConfig)(srv, ss, info, handler)
+}
 
-	ctx := ss.Context()
+func streamServerLoggerInterceptor(conf *rpclog.Config) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if skipLog(info.FullMethod) {
+			return handler(srv, ss)
+		}
 
-	prefix := strings.TrimPrefix(info.FullMethod, "/")
-	ctx = plog.With(ctx, prefix)
-	ss = newInjectServerStream(ctx, ss)
+		ctx := ss.Context()
 
-	td := plog.TimeFuncDuration()
-	err := handler(srv, ss)
-	duration := td()
-	if err != nil {
-		plog.Debugc(ctx, "Failed to handle method %s handle_time=%s handle_err=%s", info.FullMethod, duration, err)
-	} else {
-		plog.Debugc(ctx, "Succeed to handle method %s handle_time=%s", info.FullMethod, duration)
-	}
+		prefix := strings.TrimPrefix(info.FullMethod, "/")
+		ctx = plog.With(ctx, prefix)
+		ss = newInjectServerStream(ctx, ss)
+
+		td := plog.TimeFuncDuration()
+		err := handler(srv, ss)
+		duration := td()
+		if !conf.Enabled(duration) {
+			return err
+		}
 
-	return err
+		conf.Log(ctx, "handle", rpclog.Call{
+			Method:   info.FullMethod,
+			Duration: duration,
+			Err:      err,
+			Peer:     peerAddr(ctx),
+		})
+		return err
+	}
 }
@@ -0,0 +1,41 @@
+package grpcpuzzle
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-puzzles/puzzles/pmetrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+type grpcMetrics struct {
+	handled  *pmetrics.Counter
+	duration *pmetrics.Histogram
+}
+
+func newGrpcMetrics(r *pmetrics.Registry) *grpcMetrics {
+	return &grpcMetrics{
+		handled:  r.NewCounter("grpc_server_handled_total", "Total number of rpcs completed on the server.", "method", "type", "code"),
+		duration: r.NewHistogram("grpc_server_handling_seconds", "Duration of rpcs handled by the server in seconds.", pmetrics.DefBuckets, "method", "type"),
+	}
+}
+
+func (m *grpcMetrics) observe(method, typ string, start time.Time, err error) {
+	m.handled.Inc(method, typ, status.Code(err).String())
+	m.duration.Observe(time.Since(start).Seconds(), method, typ)
+}
+
+func (m *grpcMetrics) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.observe(info.FullMethod, "unary", start, err)
+	return resp, err
+}
+
+func (m *grpcMetrics) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	m.observe(info.FullMethod, "stream", start, err)
+	return err
+}
@@ -0,0 +1,41 @@
+package grpcpuzzle
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// selfBufSize is the buffer size of the in process connections of DialSelf
+const selfBufSize = 1 << 20
+
+// DialSelf connects to the grpc server of the cores service in process, e.g. to serve a ui
+// or a gateway in front of it. The connection never reaches a network listener, so it
+// needs neither TLS nor a client certificate, the interceptors still apply to its rpcs.
+func DialSelf(opt *cores.Options, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	p, ok := opt.GetPuzzle(PuzzleName)
+	if !ok {
+		return nil, errors.New("self connect to grpc: grpc puzzle is not registered")
+	}
+	g, ok := p.(*grpcPuzzles)
+	if !ok {
+		return nil, errors.Errorf("self connect to grpc: unexpected puzzle %T", p)
+	}
+
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return g.self.DialContext(ctx)
+		}),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(16 * 1024 * 1024)),
+	}
+	conn, err := grpc.NewClient("passthrough:///"+PuzzleName, append(options, opts...)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "self connect to grpc")
+	}
+	return conn, nil
+}
@@ -0,0 +1,156 @@
+package grpcpuzzle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-puzzles/puzzles/pflags"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// hasAllValidator is implemented by the protoc-gen-validate messages,
+// ValidateAll reports all the violations where Validate stops at the first one
+type hasAllValidator interface {
+	ValidateAll() error
+}
+
+// fieldError is implemented by the errors of the protoc-gen-validate messages
+type fieldError interface {
+	Field() string
+	Reason() string
+}
+
+// WithValidation rejects with InvalidArgument the requests whose ValidateAll or Validate method fails
+// or whose `validate` struct tags are not satisfied.
+func WithValidation() grpcPuzzlesOption {
+	return WithStructValidator(newValidator())
+}
+
+// WithStructValidator is WithValidation checking the struct tags with v
+func WithStructValidator(v *validator.Validate) grpcPuzzlesOption {
+	return func(gp *grpcPuzzles) {
+		gp.unaryInterceptors = append(gp.unaryInterceptors, unaryServerValidateInterceptor(v))
+		gp.streamInterceptors = append(gp.streamInterceptors, streamServerValidateInterceptor(v))
+	}
+}
+
+// newValidator returns a validator naming the fields by their json name, as the clients know them
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+func unaryServerValidateInterceptor(v *validator.Validate) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validate(v, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamServerValidateInterceptor(v *validator.Validate) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validateServerStream{ServerStream: ss, v: v})
+	}
+}
+
+type validateServerStream struct {
+	grpc.ServerStream
+	v *validator.Validate
+}
+
+func (ss *validateServerStream) RecvMsg(m interface{}) error {
+	if err := ss.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validate(ss.v, m)
+}
+
+func validate(v *validator.Validate, req interface{}) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	if hv, ok := req.(hasAllValidator); ok {
+		if err := hv.ValidateAll(); err != nil {
+			violations = append(violations, toViolations(err)...)
+		}
+	} else if hv, ok := req.(pflags.HasValidator); ok {
+		if err := hv.Validate(); err != nil {
+			violations = append(violations, toViolations(err)...)
+		}
+	}
+
+	if rv := reflect.ValueOf(req); rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct {
+		if err := v.Struct(req); err != nil {
+			violations = append(violations, toViolations(err)...)
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	descs := make([]string, 0, len(violations))
+	for _, fv := range violations {
+		if fv.Field == "" {
+			descs = append(descs, fv.Description)
+		} else {
+			descs = append(descs, fv.Field+": "+fv.Description)
+		}
+	}
+
+	st := status.New(codes.InvalidArgument, "invalid request: "+strings.Join(descs, "; "))
+	if withDetails, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// toViolations splits err into the violations of the fields
+func toViolations(err error) []*errdetails.BadRequest_FieldViolation {
+	var ves validator.ValidationErrors
+	if errors.As(err, &ves) {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(ves))
+		for _, fe := range ves {
+			// drop the name of the request message
+			_, field, _ := strings.Cut(fe.Namespace(), ".")
+			rule := fe.Tag()
+			if fe.Param() != "" {
+				rule += "=" + fe.Param()
+			}
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       field,
+				Description: "must satisfy " + rule,
+			})
+		}
+		return violations
+	}
+
+	// protoc-gen-validate joins the errors of ValidateAll in a MultiError
+	if multi, ok := err.(interface{ AllErrors() []error }); ok {
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, e := range multi.AllErrors() {
+			violations = append(violations, toViolations(e)...)
+		}
+		return violations
+	}
+
+	var fe fieldError
+	if errors.As(err, &fe) {
+		return []*errdetails.BadRequest_FieldViolation{{Field: fe.Field(), Description: fe.Reason()}}
+	}
+	return []*errdetails.BadRequest_FieldViolation{{Description: err.Error()}}
+}
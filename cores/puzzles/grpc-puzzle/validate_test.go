@@ -0,0 +1,78 @@
+package grpcpuzzle
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type createUserRequest struct {
+	Name    string   `json:"name,omitempty" validate:"required"`
+	Age     int      `json:"age,omitempty" validate:"gte=0,lte=150"`
+	Address *address `json:"address,omitempty"`
+}
+
+func (r *createUserRequest) Validate() error {
+	if r.Name == "root" {
+		return errors.New("root is reserved")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	v := newValidator()
+
+	assert.Nil(t, validate(v, &createUserRequest{Name: "alice", Age: 30}))
+	assert.Nil(t, validate(v, "not a struct"))
+
+	err := validate(v, &createUserRequest{Age: 200, Address: &address{}})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	var fields []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, fv := range br.FieldViolations {
+				fields = append(fields, fv.Field)
+			}
+		}
+	}
+	assert.Equal(t, []string{"name", "age", "address.city"}, fields)
+
+	err = validate(v, &createUserRequest{Name: "root"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "invalid request: root is reserved", status.Convert(err).Message())
+}
+
+type fieldErr struct{ field, reason string }
+
+func (e fieldErr) Error() string  { return e.field + ": " + e.reason }
+func (e fieldErr) Field() string  { return e.field }
+func (e fieldErr) Reason() string { return e.reason }
+
+type multiErr []error
+
+func (m multiErr) Error() string      { return "multiple errors" }
+func (m multiErr) AllErrors() []error { return m }
+
+// pgvRequest mimics the messages generated by protoc-gen-validate
+type pgvRequest struct{}
+
+func (r *pgvRequest) Validate() error { return fieldErr{"name", "is required"} }
+func (r *pgvRequest) ValidateAll() error {
+	return multiErr{fieldErr{"name", "is required"}, fieldErr{"age", "must be positive"}}
+}
+
+func TestValidateAll(t *testing.T) {
+	err := validate(newValidator(), &pgvRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "invalid request: name: is required; age: must be positive", status.Convert(err).Message())
+}
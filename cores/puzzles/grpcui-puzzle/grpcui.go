@@ -3,7 +3,6 @@ package grpcuipuzzle
 import (
 	"context"
 	"fmt"
-	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -13,18 +12,14 @@ import (
 	"github.com/go-puzzles/puzzles/plog"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
 	basepuzzle "github.com/go-puzzles/puzzles/cores/puzzles/base"
 	grpcpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpc-puzzle"
 )
 
+const PuzzleName = "GrpcuiPuzzle"
+
 var (
-	gup = &grpcUiPuzzles{
-		BasePuzzle: &basepuzzle.BasePuzzle{
-			PuzzleName: "GrpcuiPuzzle",
-		},
-	}
 	grpcuiUrl = "/debug/grpc/ui/"
 )
 
@@ -35,43 +30,33 @@ type grpcUiPuzzles struct {
 
 func WithCoreGrpcUI() cores.ServiceOption {
 	return func(o *cores.Options) {
-		o.RegisterPuzzle(gup)
+		o.RegisterPuzzle(&grpcUiPuzzles{
+			BasePuzzle: &basepuzzle.BasePuzzle{
+				PuzzleName: PuzzleName,
+			},
+		})
 	}
 }
 
-func (g *grpcUiPuzzles) prepareSelfConnect(lisAddr string) error {
-	_, port, _ := net.SplitHostPort(lisAddr)
-	target := fmt.Sprintf("127.0.0.1:%s", port)
-	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(16 * 1024 * 1024)),
-	}
-	conn, err := grpc.NewClient(target, opts...)
+func (g *grpcUiPuzzles) Before(opt *cores.Options) error {
+	// the grpc server may be served on another listener than the ui
+	conn, err := grpcpuzzle.DialSelf(opt)
 	if err != nil {
-		return errors.Wrap(err, "self connect to grpc")
+		return errors.Wrap(err, "prepareSelfConnect")
 	}
 	g.grpcSelfConn = conn
+
 	return nil
 }
 
-func (g *grpcUiPuzzles) Before(opt *cores.Options) error {
-	if err := g.prepareSelfConnect(opt.ListenerAddr); err != nil {
-		return errors.Wrap(err, "prepareSelfConnect")
-	}
-
-	return nil
+func (g *grpcUiPuzzles) DependsOn() []string {
+	return []string{grpcpuzzle.PuzzleName}
 }
 
 func (g *grpcUiPuzzles) StartPuzzle(ctx context.Context, opt *cores.Options) error {
 	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 
-	select {
-	case <-ctx.Done():
-		return errors.Wrap(ctx.Err(), "waitForGrpcServerInit")
-	case <-grpcpuzzle.IsGrpcServerInit():
-	}
-
 	handler, err := standalone.HandlerViaReflection(ctx, g.grpcSelfConn, opt.ServiceName)
 	if err != nil {
 		return errors.Wrap(err, "start grpcUI")
@@ -79,7 +64,7 @@ func (g *grpcUiPuzzles) StartPuzzle(ctx context.Context, opt *cores.Options) err
 
 	opt.HttpMux.Handle(grpcuiUrl, http.StripPrefix(strings.TrimSuffix(grpcuiUrl, "/"), handler))
 
-	plog.Debugc(ctx, "GrpcuiPuzzle enabled. URL=%s", fmt.Sprintf("http://%s%s", g.grpcSelfConn.Target(), grpcuiUrl))
+	plog.Debugc(ctx, "GrpcuiPuzzle enabled. URL=%s", fmt.Sprintf("http://%s%s", opt.ListenerAddr, grpcuiUrl))
 	return nil
 }
 
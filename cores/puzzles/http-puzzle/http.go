@@ -13,29 +13,44 @@ import (
 	"github.com/rs/cors"
 
 	basepuzzle "github.com/go-puzzles/puzzles/cores/puzzles/base"
+	pprofpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/pprof-puzzle"
 )
 
+const PuzzleName = "HttpPuzzle"
+
 type httpPuzzles struct {
 	*basepuzzle.BasePuzzle
 	httpCors bool
-	pattern  string
+	patterns []string
 	router   *mux.Router
 }
 
-var (
-	hp = &httpPuzzles{
+func newHttpPuzzles() *httpPuzzles {
+	return &httpPuzzles{
 		BasePuzzle: &basepuzzle.BasePuzzle{
-			PuzzleName: "HttpPuzzle",
+			PuzzleName: PuzzleName,
 		},
 		router: mux.NewRouter(),
 	}
-)
+}
+
+// getHttpPuzzles returns the http puzzle of the cores service, creating and registering it if necessary.
+func getHttpPuzzles(o *cores.Options) *httpPuzzles {
+	if p, ok := o.GetPuzzle(PuzzleName); ok {
+		if hp, ok := p.(*httpPuzzles); ok {
+			return hp
+		}
+	}
+
+	hp := newHttpPuzzles()
+	o.RegisterPuzzle(hp)
+	return hp
+}
 
 func WithCoreHttpCORS() cores.ServiceOption {
 	return func(o *cores.Options) {
-		hp.httpCors = true
+		getHttpPuzzles(o).httpCors = true
 		plog.Debugf("Http enable CORS")
-		o.RegisterPuzzle(hp)
 	}
 }
 
@@ -45,7 +60,8 @@ func WithCoreHttpPuzzle(pattern string, handler http.Handler) cores.ServiceOptio
 			pattern = "/" + pattern
 		}
 
-		hp.pattern = pattern
+		hp := getHttpPuzzles(o)
+		hp.patterns = append(hp.patterns, pattern)
 		hp.router.PathPrefix(pattern).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if pattern == "/" || pattern == "" {
 				handler.ServeHTTP(w, r)
@@ -53,36 +69,31 @@ func WithCoreHttpPuzzle(pattern string, handler http.Handler) cores.ServiceOptio
 			}
 			http.StripPrefix(pattern, handler).ServeHTTP(w, r)
 		}))
-		o.RegisterPuzzle(hp)
 	}
 }
 
-func (h *httpPuzzles) waitForOtherPuzzles(opt *cores.Options) {
-	if opt.WaitPprof != nil {
-		select {
-		case <-opt.WaitPprof:
-		}
-	}
+func (h *httpPuzzles) DependsOn() []string {
+	return []string{pprofpuzzle.PuzzleName}
 }
 
 func (h *httpPuzzles) StartPuzzle(ctx context.Context, opt *cores.Options) error {
-	h.waitForOtherPuzzles(opt)
-
 	var handler http.Handler = h.router
 	if h.httpCors {
 		handler = cors.AllowAll().Handler(handler)
 	}
 
-	pattern := hp.pattern
-	if pattern != "/" {
-		pattern += "/"
-	}
-
-	opt.HttpMux.Handle(pattern, h.router)
-
 	_, port, _ := net.SplitHostPort(opt.ListenerAddr)
 	target := fmt.Sprintf("127.0.0.1:%s", port)
-	plog.Debugc(ctx, "HttpPuzzle enabled. URL=%s", fmt.Sprintf("http://%s%s", target, h.pattern))
+
+	for _, pattern := range h.patterns {
+		muxPattern := pattern
+		if muxPattern != "/" {
+			muxPattern += "/"
+		}
+
+		opt.HttpMux.Handle(muxPattern, handler)
+		plog.Debugc(ctx, "HttpPuzzle enabled. URL=%s", fmt.Sprintf("http://%s%s", target, pattern))
+	}
 
 	return nil
 }
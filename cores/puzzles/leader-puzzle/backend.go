@@ -0,0 +1,74 @@
+package leaderpuzzle
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-puzzles/puzzles/goredis"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend stores the lease of the leader
+type Backend interface {
+	// Acquire makes identity the leader of key for ttl, or extends its lease if it is already the leader.
+	// It returns false if another identity holds the lease.
+	Acquire(ctx context.Context, key, identity string, ttl time.Duration) (bool, error)
+	// Release gives up the lease of key if it is held by identity
+	Release(ctx context.Context, key, identity string) error
+	// Leader returns the identity holding the lease of key, or an empty string if there is none
+	Leader(ctx context.Context, key string) (string, error)
+}
+
+const acquireScript = `
+	local v = redis.call('get', KEYS[1])
+	if v == ARGV[1] then
+		redis.call('pexpire', KEYS[1], ARGV[2])
+		return 1
+	end
+	if not v then
+		redis.call('set', KEYS[1], ARGV[1], 'PX', ARGV[2])
+		return 1
+	end
+	return 0`
+
+const releaseScript = `
+	if redis.call('get', KEYS[1]) == ARGV[1] then
+		return redis.call('del', KEYS[1])
+	end
+	return 0`
+
+type redisBackend struct {
+	client *goredis.PuzzleRedisClient
+}
+
+// NewRedisBackend returns a Backend keeping the lease in redis
+func NewRedisBackend(client *goredis.PuzzleRedisClient) Backend {
+	return &redisBackend{client: client}
+}
+
+func (b *redisBackend) Acquire(ctx context.Context, key, identity string, ttl time.Duration) (bool, error) {
+	ret, err := b.client.Eval(ctx, acquireScript, []string{key}, identity, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, errors.Wrap(err, "acquireLease")
+	}
+	return ret == 1, nil
+}
+
+func (b *redisBackend) Release(ctx context.Context, key, identity string) error {
+	if err := b.client.Eval(ctx, releaseScript, []string{key}, identity).Err(); err != nil {
+		return errors.Wrap(err, "releaseLease")
+	}
+	return nil
+}
+
+func (b *redisBackend) Leader(ctx context.Context, key string) (string, error) {
+	leader, err := b.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "getLeader")
+	}
+	return leader, nil
+}
@@ -0,0 +1,403 @@
+package leaderpuzzle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/plog"
+	"github.com/pkg/errors"
+
+	basepuzzle "github.com/go-puzzles/puzzles/cores/puzzles/base"
+)
+
+const PuzzleName = "LeaderPuzzle"
+
+const (
+	defaultLeaseTTL    = 15 * time.Second
+	defaultStopTimeout = 5 * time.Second
+)
+
+var (
+	leaderUrl = "/leader"
+)
+
+type leaderPuzzle struct {
+	*basepuzzle.BasePuzzle
+	backend       Backend
+	key           string
+	identity      string
+	leaseTTL      time.Duration
+	renewInterval time.Duration
+	safetyMargin  time.Duration
+	stopTimeout   time.Duration
+	workers       []*cores.Supervisor
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+
+	mu        sync.Mutex
+	leader    string
+	isLeader  bool
+	since     time.Time
+	lastRenew time.Time
+	term      *term
+}
+
+// term holds the workers started for one leadership
+type term struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]struct{}
+}
+
+func (t *term) done(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.running, name)
+}
+
+func (t *term) stillRunning() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.running))
+	for name := range t.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type leaderPuzzleOption func(lp *leaderPuzzle)
+
+// WithLeaderKey sets the key of the lease, the default is "leader:<service name>".
+func WithLeaderKey(key string) leaderPuzzleOption {
+	return func(lp *leaderPuzzle) {
+		lp.key = key
+	}
+}
+
+// WithLeaderIdentity sets the identity of this instance, the default is "<hostname>:<pid>".
+func WithLeaderIdentity(identity string) leaderPuzzleOption {
+	return func(lp *leaderPuzzle) {
+		lp.identity = identity
+	}
+}
+
+// WithLeaseTTL sets how long the lease is valid without being renewed, the default is 15s.
+func WithLeaseTTL(ttl time.Duration) leaderPuzzleOption {
+	return func(lp *leaderPuzzle) {
+		lp.leaseTTL = ttl
+	}
+}
+
+// WithRenewInterval sets how often the lease is renewed or campaigned for, the default is a third of the lease ttl.
+// A single campaign may take at most the renew interval.
+func WithRenewInterval(interval time.Duration) leaderPuzzleOption {
+	return func(lp *leaderPuzzle) {
+		lp.renewInterval = interval
+	}
+}
+
+// WithLeaseSafetyMargin sets how long before the lease expires the leader steps down when it could not
+// renew it, so that its workers are stopped before another instance takes over. The default is the renew interval.
+func WithLeaseSafetyMargin(margin time.Duration) leaderPuzzleOption {
+	return func(lp *leaderPuzzle) {
+		lp.safetyMargin = margin
+	}
+}
+
+// WithLeaderStopTimeout sets how long the leader workers may take to return once the leadership is lost
+// or the service stops, the default is 5s.
+func WithLeaderStopTimeout(timeout time.Duration) leaderPuzzleOption {
+	return func(lp *leaderPuzzle) {
+		lp.stopTimeout = timeout
+	}
+}
+
+func newLeaderPuzzle() *leaderPuzzle {
+	hostname, _ := os.Hostname()
+	return &leaderPuzzle{
+		BasePuzzle: &basepuzzle.BasePuzzle{
+			PuzzleName: PuzzleName,
+		},
+		identity:    fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		leaseTTL:    defaultLeaseTTL,
+		stopTimeout: defaultStopTimeout,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// getLeaderPuzzle returns the leader puzzle of the cores service, creating and registering it if necessary.
+func getLeaderPuzzle(o *cores.Options) *leaderPuzzle {
+	if p, ok := o.GetPuzzle(PuzzleName); ok {
+		if lp, ok := p.(*leaderPuzzle); ok {
+			return lp
+		}
+	}
+
+	lp := newLeaderPuzzle()
+	o.RegisterPuzzle(lp)
+	return lp
+}
+
+// WithCoreLeader makes the service campaign for leadership using backend.
+func WithCoreLeader(backend Backend, opts ...leaderPuzzleOption) cores.ServiceOption {
+	return func(o *cores.Options) {
+		lp := getLeaderPuzzle(o)
+		lp.backend = backend
+
+		for _, opt := range opts {
+			opt(lp)
+		}
+
+		plog.Debugf("Leader election enabled.")
+	}
+}
+
+// WithLeaderWorker adds a worker which only runs while this instance is the leader.
+// Its context is cancelled when the leadership is lost and it is started again when the leadership is regained.
+// A worker returning during the leadership is restarted according to its restart policy, see cores.WithRestartPolicy.
+// The leader workers are listed by Options.Workers with the "leader" kind.
+func WithLeaderWorker(name string, fn cores.WorkerFunc, opts ...cores.WorkerOption) cores.ServiceOption {
+	return func(o *cores.Options) {
+		lp := getLeaderPuzzle(o)
+		lp.workers = append(lp.workers, o.NewSupervisor("leader", name, fn, opts...))
+	}
+}
+
+func (lp *leaderPuzzle) Before(opt *cores.Options) error {
+	if lp.backend == nil {
+		return errors.New("leader puzzle need a backend, use WithCoreLeader to set it")
+	}
+
+	if lp.key == "" {
+		lp.key = fmt.Sprintf("leader:%s", opt.ServiceName)
+	}
+
+	if lp.renewInterval <= 0 {
+		lp.renewInterval = lp.leaseTTL / 3
+	}
+	if lp.safetyMargin <= 0 {
+		lp.safetyMargin = lp.renewInterval
+	}
+	if lp.safetyMargin >= lp.leaseTTL {
+		return errors.Errorf("leader puzzle lease safety margin %v must be lower than the lease ttl %v", lp.safetyMargin, lp.leaseTTL)
+	}
+
+	return nil
+}
+
+func (lp *leaderPuzzle) StartPuzzle(ctx context.Context, opt *cores.Options) error {
+	opt.HttpMux.HandleFunc(leaderUrl, lp.serveStatus)
+	opt.MarkReady(lp.Name())
+
+	plog.Infoc(ctx, "LeaderPuzzle enabled. Key=%s Identity=%s", lp.key, lp.identity)
+
+	ticker := time.NewTicker(lp.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		lp.campaign(ctx)
+
+		select {
+		case <-ctx.Done():
+			lp.resign(ctx)
+			return nil
+		case <-lp.stopCh:
+			lp.resign(ctx)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// StopPriority makes the leader workers stop together with the other workers
+func (lp *leaderPuzzle) MarksReady() bool {
+	return true
+}
+
+func (lp *leaderPuzzle) StopPriority() int {
+	return cores.StopPriorityWorker
+}
+
+func (lp *leaderPuzzle) StopTimeout() time.Duration {
+	return lp.stopTimeout
+}
+
+func (lp *leaderPuzzle) Stop() error {
+	lp.stopOnce.Do(func() {
+		close(lp.stopCh)
+	})
+	return nil
+}
+
+func (lp *leaderPuzzle) campaign(ctx context.Context) {
+	// the lease is only known to be valid from the time the request was sent
+	start := time.Now()
+	acquireCtx, cancel := context.WithTimeout(ctx, lp.renewInterval)
+	ok, err := lp.backend.Acquire(acquireCtx, lp.key, lp.identity, lp.leaseTTL)
+	cancel()
+	if err != nil {
+		plog.Warnc(ctx, "Campaign for leadership error: %v", err)
+
+		// step down before the lease expires, another instance may take over as soon as it does
+		lp.mu.Lock()
+		expiring := lp.isLeader && time.Since(lp.lastRenew) >= lp.leaseTTL-lp.safetyMargin
+		lp.mu.Unlock()
+		if expiring {
+			lp.stepDown(ctx, "lease not renewed in time")
+		}
+		return
+	}
+
+	if ok {
+		lp.mu.Lock()
+		lp.lastRenew = start
+		lp.mu.Unlock()
+		lp.becomeLeader(ctx)
+		return
+	}
+
+	lp.stepDown(ctx, "lease held by another instance")
+
+	leaderCtx, cancel := context.WithTimeout(ctx, lp.renewInterval)
+	defer cancel()
+	leader, err := lp.backend.Leader(leaderCtx, lp.key)
+	if err != nil {
+		plog.Warnc(ctx, "Get current leader error: %v", err)
+		return
+	}
+	lp.setLeader(ctx, leader)
+}
+
+func (lp *leaderPuzzle) setLeader(ctx context.Context, leader string) {
+	lp.mu.Lock()
+	changed := lp.leader != leader
+	lp.leader = leader
+	lp.mu.Unlock()
+
+	if changed {
+		plog.Infoc(ctx, "Current leader: %v", leader)
+	}
+}
+
+func (lp *leaderPuzzle) becomeLeader(ctx context.Context) {
+	lp.mu.Lock()
+	if lp.isLeader {
+		lp.mu.Unlock()
+		return
+	}
+
+	termCtx, cancel := context.WithCancel(ctx)
+	t := &term{cancel: cancel, running: make(map[string]struct{}, len(lp.workers))}
+	lp.isLeader = true
+	lp.leader = lp.identity
+	lp.since = time.Now()
+	lp.term = t
+	for _, w := range lp.workers {
+		t.running[w.Name()] = struct{}{}
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			defer t.done(w.Name())
+
+			wctx := plog.With(termCtx, "Worker", w.Name())
+			if err := w.Run(wctx); err != nil && !errors.Is(err, context.Canceled) {
+				plog.Errorc(wctx, "leader worker: %v run error: %v", w.Name(), err)
+			}
+		}()
+	}
+	lp.mu.Unlock()
+
+	plog.Infoc(ctx, "Leadership acquired. Key=%s Identity=%s", lp.key, lp.identity)
+}
+
+func (lp *leaderPuzzle) stepDown(ctx context.Context, reason string) {
+	lp.mu.Lock()
+	if !lp.isLeader {
+		lp.mu.Unlock()
+		return
+	}
+
+	t := lp.term
+	lp.isLeader = false
+	lp.leader = ""
+	lp.term = nil
+	lp.mu.Unlock()
+
+	t.cancel()
+	plog.Warnc(ctx, "Leadership lost: %s. Key=%s Identity=%s", reason, lp.key, lp.identity)
+
+	stopped := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(lp.stopTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+	case <-timer.C:
+		plog.Errorc(ctx, "Leader workers did not stop within %v: %v", lp.stopTimeout, strings.Join(t.stillRunning(), ", "))
+	}
+}
+
+func (lp *leaderPuzzle) resign(ctx context.Context) {
+	lp.mu.Lock()
+	wasLeader := lp.isLeader
+	lp.mu.Unlock()
+
+	if !wasLeader {
+		return
+	}
+	lp.stepDown(ctx, "service stopping")
+
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+	defer cancel()
+	if err := lp.backend.Release(releaseCtx, lp.key, lp.identity); err != nil {
+		plog.Warnc(ctx, "Release leadership error: %v", err)
+	}
+}
+
+type leaderStatus struct {
+	Key      string     `json:"key"`
+	Identity string     `json:"identity"`
+	Leader   string     `json:"leader"`
+	IsLeader bool       `json:"isLeader"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+func (lp *leaderPuzzle) status() leaderStatus {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	s := leaderStatus{
+		Key:      lp.key,
+		Identity: lp.identity,
+		Leader:   lp.leader,
+		IsLeader: lp.isLeader,
+	}
+	if lp.isLeader {
+		since := lp.since
+		s.Since = &since
+	}
+	return s
+}
+
+func (lp *leaderPuzzle) serveStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lp.status())
+}
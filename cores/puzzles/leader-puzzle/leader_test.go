@@ -0,0 +1,174 @@
+package leaderpuzzle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/stretchr/testify/assert"
+)
+
+type memBackend struct {
+	mu     sync.Mutex
+	leader string
+}
+
+func (b *memBackend) Acquire(_ context.Context, _, identity string, _ time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.leader == "" || b.leader == identity {
+		b.leader = identity
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b *memBackend) Release(_ context.Context, _, identity string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.leader == identity {
+		b.leader = ""
+	}
+	return nil
+}
+
+func (b *memBackend) Leader(_ context.Context, _ string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.leader, nil
+}
+
+func newTestLeader(t *testing.T, backend Backend, identity string, fn cores.WorkerFunc) *leaderPuzzle {
+	lp := newLeaderPuzzle()
+	lp.backend = backend
+	lp.identity = identity
+	lp.workers = append(lp.workers, (&cores.Options{}).NewSupervisor("leader", "worker", fn))
+
+	assert.Nil(t, lp.Before(&cores.Options{ServiceName: "test"}))
+	return lp
+}
+
+func TestLeaderPuzzle_Campaign(t *testing.T) {
+	ctx := context.Background()
+	backend := &memBackend{}
+
+	running := make(chan string, 2)
+	stopped := make(chan string, 2)
+	worker := func(identity string) cores.WorkerFunc {
+		return func(ctx context.Context) error {
+			running <- identity
+			<-ctx.Done()
+			stopped <- identity
+			return ctx.Err()
+		}
+	}
+
+	a := newTestLeader(t, backend, "a", worker("a"))
+	b := newTestLeader(t, backend, "b", worker("b"))
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	assert.Equal(t, "a", <-running)
+	assert.True(t, a.status().IsLeader)
+	assert.Equal(t, "a", b.status().Leader)
+	assert.False(t, b.status().IsLeader)
+
+	// a loses the lease, b takes over on its next campaign
+	backend.Release(ctx, "", "a")
+	backend.Acquire(ctx, "", "b", time.Second)
+	a.campaign(ctx)
+	assert.Equal(t, "a", <-stopped)
+	b.campaign(ctx)
+	assert.Equal(t, "b", <-running)
+
+	b.resign(ctx)
+	assert.Equal(t, "b", <-stopped)
+	leader, _ := backend.Leader(ctx, "")
+	assert.Equal(t, "", leader)
+}
+
+type failingBackend struct {
+	memBackend
+	fail bool
+}
+
+func (b *failingBackend) Acquire(ctx context.Context, key, identity string, ttl time.Duration) (bool, error) {
+	if b.fail {
+		return false, errors.New("backend unreachable")
+	}
+	return b.memBackend.Acquire(ctx, key, identity, ttl)
+}
+
+func TestLeaderPuzzle_StepDownBeforeExpiry(t *testing.T) {
+	ctx := context.Background()
+	backend := &failingBackend{}
+
+	stopped := make(chan struct{})
+	lp := newTestLeader(t, backend, "a", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+
+	lp.campaign(ctx)
+	assert.True(t, lp.status().IsLeader)
+
+	// the lease is still valid for longer than the safety margin
+	backend.fail = true
+	lp.campaign(ctx)
+	assert.True(t, lp.status().IsLeader)
+
+	lp.mu.Lock()
+	lp.lastRenew = time.Now().Add(-(lp.leaseTTL - lp.safetyMargin))
+	lp.mu.Unlock()
+	lp.campaign(ctx)
+	assert.False(t, lp.status().IsLeader)
+	<-stopped
+}
+
+func TestLeaderPuzzle_StepDownTimeout(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	defer close(release)
+
+	lp := newTestLeader(t, &memBackend{}, "a", func(context.Context) error {
+		<-release
+		return nil
+	})
+	lp.stopTimeout = 50 * time.Millisecond
+
+	lp.campaign(ctx)
+	start := time.Now()
+	lp.resign(ctx)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, lp.status().IsLeader)
+}
+
+func TestLeaderPuzzle_RestartWorker(t *testing.T) {
+	ctx := context.Background()
+	runs := make(chan struct{}, 3)
+
+	opts := &cores.Options{}
+	lp := newLeaderPuzzle()
+	lp.backend = &memBackend{}
+	lp.workers = append(lp.workers, opts.NewSupervisor("leader", "worker", func(ctx context.Context) error {
+		runs <- struct{}{}
+		return errors.New("failed")
+	}, cores.WithRestartPolicy(cores.RestartOnFailure), cores.WithMaxRestarts(2), cores.WithRestartBackoff(time.Millisecond, time.Millisecond)))
+	assert.Nil(t, lp.Before(&cores.Options{ServiceName: "test"}))
+
+	lp.campaign(ctx)
+	for range 3 {
+		<-runs
+	}
+	lp.resign(ctx)
+
+	workers := opts.Workers()
+	assert.Len(t, workers, 1)
+	assert.Equal(t, "leader", workers[0].Kind)
+	assert.Equal(t, int64(2), workers[0].Restarts)
+	assert.Equal(t, "failed", workers[0].LastError)
+}
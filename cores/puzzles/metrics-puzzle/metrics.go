@@ -0,0 +1,72 @@
+package metricspuzzle
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-puzzles/puzzles/cores"
+	"github.com/go-puzzles/puzzles/plog"
+	"github.com/go-puzzles/puzzles/pmetrics"
+
+	basepuzzle "github.com/go-puzzles/puzzles/cores/puzzles/base"
+)
+
+const PuzzleName = "MetricsPuzzle"
+
+var (
+	metricsUrl = "/metrics"
+)
+
+type metricsPuzzle struct {
+	*basepuzzle.BasePuzzle
+	registry *pmetrics.Registry
+}
+
+type metricsPuzzleOption func(mp *metricsPuzzle)
+
+// WithRegistry makes the puzzle expose and record into r instead of pmetrics.Default(),
+// it keeps apart the metrics of several cores in one process. The pool stats of pgorm and
+// the redis dialers and the pgin middleware are only exposed if they are given r as well.
+func WithRegistry(r *pmetrics.Registry) metricsPuzzleOption {
+	return func(mp *metricsPuzzle) {
+		mp.registry = r
+	}
+}
+
+// WithCoreMetrics exposes the metrics in the Prometheus text format on /metrics and
+// enables the built-in instrumentation of the http handler, the grpc server and the cron workers.
+// It serves pmetrics.Default() by default, along with the pool stats of the dialers and the pgin middleware.
+func WithCoreMetrics(opts ...metricsPuzzleOption) cores.ServiceOption {
+	return func(o *cores.Options) {
+		mp := &metricsPuzzle{
+			BasePuzzle: &basepuzzle.BasePuzzle{
+				PuzzleName: PuzzleName,
+			},
+			registry: pmetrics.Default(),
+		}
+
+		for _, opt := range opts {
+			opt(mp)
+		}
+
+		o.Metrics = mp.registry
+		o.RegisterPuzzle(mp)
+		plog.Debugf("Metrics enabled.")
+	}
+}
+
+func (m *metricsPuzzle) Before(opt *cores.Options) error {
+	opt.HttpHandler = pmetrics.InstrumentHandler(m.registry, opt.HttpHandler)
+	return nil
+}
+
+func (m *metricsPuzzle) StartPuzzle(ctx context.Context, opt *cores.Options) error {
+	opt.HttpMux.Handle(metricsUrl, pmetrics.Handler(m.registry))
+
+	plog.Debugc(ctx, "MetricsPuzzle enabled. URL=%s", fmt.Sprintf("http://%s%s", opt.ListenerAddr, metricsUrl))
+	return nil
+}
+
+func (m *metricsPuzzle) Stop() error {
+	return nil
+}
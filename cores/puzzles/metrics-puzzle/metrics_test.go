@@ -0,0 +1,30 @@
+package metricspuzzle_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/go-puzzles/puzzles/cores/coretest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	metricspuzzle "github.com/go-puzzles/puzzles/cores/puzzles/metrics-puzzle"
+	redisdialer "github.com/go-puzzles/puzzles/dialer/redis"
+)
+
+func TestMetrics_PoolStats(t *testing.T) {
+	cli := redisdialer.DialGoRedisClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	defer redisdialer.CloseGoRedisClient(cli)
+
+	s := coretest.Start(t, metricspuzzle.WithCoreMetrics())
+
+	resp, err := s.HTTP.Get(s.URL("/metrics"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `redis_pool_connections{client="goredis",addr="127.0.0.1:6379",db="0",state="total"} 0`)
+}
@@ -22,12 +22,9 @@ import (
 	basepuzzle "github.com/go-puzzles/puzzles/cores/puzzles/base"
 )
 
+const PuzzleName = "PprofPuzzle"
+
 var (
-	pp = &pprofPuzzles{
-		BasePuzzle: &basepuzzle.BasePuzzle{
-			PuzzleName: "PprofPuzzle",
-		},
-	}
 	pprofUrl = "/debug/pprof/"
 )
 
@@ -37,8 +34,11 @@ type pprofPuzzles struct {
 
 func WithCorePprof() cores.ServiceOption {
 	return func(o *cores.Options) {
-		o.WaitPprof = make(chan struct{})
-		o.RegisterPuzzle(pp)
+		o.RegisterPuzzle(&pprofPuzzles{
+			BasePuzzle: &basepuzzle.BasePuzzle{
+				PuzzleName: PuzzleName,
+			},
+		})
 	}
 }
 
@@ -65,7 +65,6 @@ func (p *pprofPuzzles) StartPuzzle(ctx context.Context, opts *cores.Options) err
 	registerHandler("/symbol").HandlerFunc(pprof.Symbol)
 
 	opts.HttpMux.Handle(pprofUrl, http.StripPrefix("/debug/pprof", router))
-	opts.WaitPprof <- struct{}{}
 
 	plog.Debugc(ctx, "PprofPuzzle enabled. URL=%s", fmt.Sprintf("http://%s%s", target, pprofUrl))
 	return nil
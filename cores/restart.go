@@ -0,0 +1,292 @@
+package cores
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/go-puzzles/puzzles/plog"
+)
+
+const (
+	// envListenFds lists the network:address of the listeners inherited from the parent process,
+	// their file descriptors start at 3 in the same order
+	envListenFds = "GO_PUZZLES_LISTEN_FDS"
+	// envReadyFd is the file descriptor the child writes to once it is ready
+	envReadyFd = "GO_PUZZLES_READY_FD"
+
+	defaultGracefulRestartTimeout = 30 * time.Second
+)
+
+type gracefulRestartOptions struct {
+	signal       os.Signal
+	readyTimeout time.Duration
+}
+
+type GracefulRestartOption func(*gracefulRestartOptions)
+
+// WithGracefulRestartSignal sets the signal triggering the restart, the default is SIGUSR2.
+func WithGracefulRestartSignal(sig os.Signal) GracefulRestartOption {
+	return func(o *gracefulRestartOptions) {
+		o.signal = sig
+	}
+}
+
+// WithGracefulRestartTimeout sets how long the new process has to become ready before the restart is aborted.
+func WithGracefulRestartTimeout(timeout time.Duration) GracefulRestartOption {
+	return func(o *gracefulRestartOptions) {
+		o.readyTimeout = timeout
+	}
+}
+
+// WithGracefulRestart enables zero-downtime restarts: on the restart signal the binary is started again
+// with the listening sockets handed over, once all its puzzles are ready this process drains and exits.
+// See HasReadiness for when a puzzle is ready.
+// If the new process fails to become ready, it is killed and this process keeps serving.
+func WithGracefulRestart(opts ...GracefulRestartOption) ServiceOption {
+	return func(o *Options) {
+		r := &gracefulRestartOptions{
+			signal:       defaultRestartSignal,
+			readyTimeout: defaultGracefulRestartTimeout,
+		}
+
+		for _, opt := range opts {
+			opt(r)
+		}
+
+		o.restart = r
+	}
+}
+
+var (
+	inheritOnce sync.Once
+	inherited   map[string]net.Listener
+)
+
+func listenerKey(network, addr string) string {
+	return network + ":" + addr
+}
+
+// inheritedListener returns the listener handed over by the parent process for network and addr
+func inheritedListener(network, addr string) (net.Listener, bool) {
+	inheritOnce.Do(func() {
+		keys := os.Getenv(envListenFds)
+		os.Unsetenv(envListenFds)
+		inherited = inheritListeners(keys, 3)
+	})
+
+	lis, ok := inherited[listenerKey(network, addr)]
+	if ok {
+		delete(inherited, listenerKey(network, addr))
+	}
+	return lis, ok
+}
+
+// inheritListeners opens the listeners of the comma separated network:address keys,
+// their file descriptors start at firstFd in the same order
+func inheritListeners(keys string, firstFd int) map[string]net.Listener {
+	listeners := make(map[string]net.Listener)
+	if keys == "" {
+		return listeners
+	}
+
+	for i, key := range strings.Split(keys, ",") {
+		f := os.NewFile(uintptr(firstFd+i), key)
+		lis, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			plog.Errorf("Inherit listener %s error: %v", key, err)
+			continue
+		}
+		listeners[key] = lis
+	}
+	return listeners
+}
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+// listenerFiles returns the file descriptors of the listeners to hand over to the new process
+func (c *CoreService) listenerFiles() ([]string, []*os.File, error) {
+	type entry struct {
+		key string
+		lis net.Listener
+	}
+
+	var entries []entry
+	if c.rawListener != nil {
+		entries = append(entries, entry{listenerKey("tcp", c.listenAddr), c.rawListener})
+	}
+	for _, l := range c.opts.listeners {
+		entries = append(entries, entry{listenerKey(l.Network, l.listenAddr), l.lis})
+	}
+
+	var (
+		keys  []string
+		files []*os.File
+	)
+	for _, e := range entries {
+		fl, ok := e.lis.(filer)
+		if !ok {
+			closeFiles(files)
+			return nil, nil, perror.PackError(500, fmt.Sprintf("listener %s can not be handed over", e.key))
+		}
+
+		// the socket file must stay in place for the new process when this one closes the listener
+		if ul, ok := e.lis.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+
+		f, err := fl.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, perror.WrapError(500, err, fmt.Sprintf("get file of listener %s", e.key))
+		}
+		keys = append(keys, e.key)
+		files = append(files, f)
+	}
+
+	return keys, files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// restart starts the new process and waits for it to be ready
+func (c *CoreService) restart(ctx context.Context) error {
+	keys, files, err := c.listenerFiles()
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return perror.WrapError(500, err, "create ready pipe")
+	}
+	defer readyR.Close()
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envListenFds+"=") && !strings.HasPrefix(kv, envReadyFd+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		fmt.Sprintf("%s=%s", envListenFds, strings.Join(keys, ",")),
+		fmt.Sprintf("%s=%d", envReadyFd, 3+len(files)),
+	)
+
+	bin, err := os.Executable()
+	if err != nil {
+		readyW.Close()
+		return perror.WrapError(500, err, "find executable")
+	}
+
+	cmd := exec.Command(bin, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return perror.WrapError(500, err, "start new process")
+	}
+	plog.Infoc(ctx, "Started new process. Pid=%d", cmd.Process.Pid)
+
+	ready := make(chan error, 1)
+	go func() {
+		// the child writes once it is ready, the read fails if it exits before
+		buf := make([]byte, 1)
+		_, err := readyR.Read(buf)
+		ready <- err
+	}()
+
+	timer := time.NewTimer(c.opts.restart.readyTimeout)
+	defer timer.Stop()
+
+	select {
+	case err = <-ready:
+		if err == nil {
+			// the child is now on its own
+			go cmd.Wait()
+			return nil
+		}
+		err = perror.WrapError(500, err, "new process exited before being ready")
+	case <-timer.C:
+		err = perror.PackError(500, "new process not ready in time")
+	case <-ctx.Done():
+		err = perror.WrapError(500, ctx.Err(), "restart cancelled")
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+	return err
+}
+
+func (c *CoreService) gracefulRestart() mountFn {
+	return mountFn{
+		name: "GracefulRestart",
+		fn: func(ctx context.Context) error {
+			ch := make(chan os.Signal, 1)
+			signal.Notify(ch, c.opts.restart.signal)
+			defer signal.Stop(ch)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ch:
+				}
+
+				plog.Infoc(ctx, "Graceful restart requested")
+				if err := c.restart(ctx); err != nil {
+					plog.Errorc(ctx, "Graceful restart failed, keep serving: %v", err)
+					continue
+				}
+
+				plog.Infoc(ctx, "New process is ready, draining this one")
+				c.gracefulStop(context.Background())
+				return nil
+			}
+		},
+		skipStop: true,
+	}
+}
+
+// notifyReady tells the parent process that this one is ready
+func (c *CoreService) notifyReady(ctx context.Context) {
+	fdStr := os.Getenv(envReadyFd)
+	if fdStr == "" {
+		return
+	}
+	os.Unsetenv(envReadyFd)
+
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		plog.Errorc(ctx, "Invalid %s: %v", envReadyFd, fdStr)
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+
+	if _, err := f.Write([]byte{1}); err != nil {
+		plog.Errorc(ctx, "Notify parent process error: %v", err)
+		return
+	}
+	plog.Infoc(ctx, "Notified parent process of readiness")
+}
@@ -0,0 +1,140 @@
+//go:build !windows
+
+package cores
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dupFd returns a copy of the file descriptor of f, owned by the caller
+func dupFd(t *testing.T, f *os.File) int {
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("dup: %v", err)
+	}
+	return fd
+}
+
+func TestRestart_InheritListeners(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer lis.Close()
+
+	f, err := lis.(*net.TCPListener).File()
+	if !assert.Nil(t, err) {
+		return
+	}
+	fd := dupFd(t, f)
+	f.Close()
+
+	key := listenerKey("tcp", lis.Addr().String())
+	inherited := inheritListeners(key, fd)
+	child, ok := inherited[key]
+	if !assert.True(t, ok) {
+		return
+	}
+	defer child.Close()
+	assert.Equal(t, lis.Addr().String(), child.Addr().String())
+
+	// the inherited listener accepts on the same socket
+	go func() {
+		if conn, err := net.Dial("tcp", lis.Addr().String()); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := child.Accept()
+	if assert.Nil(t, err) {
+		conn.Close()
+	}
+
+	assert.Empty(t, inheritListeners("", 3))
+}
+
+func TestRestart_ListenerFiles(t *testing.T) {
+	srv := NewPuzzleCore(WithListener("local", "unix", fmt.Sprintf("%s/app.sock", t.TempDir())))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer lis.Close()
+	srv.rawListener = lis
+	srv.listenAddr = ":0"
+
+	assert.Nil(t, srv.listenExtra())
+	defer srv.closeExtra()
+
+	keys, files, err := srv.listenerFiles()
+	assert.Nil(t, err)
+	defer closeFiles(files)
+	assert.Equal(t, []string{"tcp::0", "unix:" + srv.opts.listeners[0].listenAddr}, keys)
+	assert.Len(t, files, 2)
+}
+
+func TestRestart_NotifyReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer r.Close()
+	fd := dupFd(t, w)
+	w.Close()
+
+	t.Setenv(envReadyFd, strconv.Itoa(fd))
+	srv := NewPuzzleCore()
+	srv.notifyReady(context.Background())
+	_, ok := os.LookupEnv(envReadyFd)
+	assert.False(t, ok)
+
+	// the only write end is closed once notified
+	buf := make([]byte, 2)
+	n, _ := r.Read(buf)
+	assert.Equal(t, 1, n)
+	_, err = r.Read(buf)
+	assert.NotNil(t, err)
+
+	// an invalid descriptor is ignored
+	t.Setenv(envReadyFd, "ready")
+	srv.notifyReady(context.Background())
+}
+
+type blockingPuzzle struct{}
+
+func (blockingPuzzle) Name() string          { return "blocking" }
+func (blockingPuzzle) Before(*Options) error { return nil }
+func (blockingPuzzle) Stop() error           { return nil }
+func (blockingPuzzle) StartPuzzle(ctx context.Context, _ *Options) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestRestart_ReadyWithoutMarkReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer r.Close()
+	fd := dupFd(t, w)
+	w.Close()
+	t.Setenv(envReadyFd, strconv.Itoa(fd))
+
+	// a puzzle blocking in StartPuzzle without calling MarkReady does not hold the parent
+	srv := NewPuzzleCore(func(o *Options) { o.RegisterPuzzle(blockingPuzzle{}) })
+	startTestCore(t, srv)
+	defer srv.Shutdown(context.Background())
+
+	r.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, err := r.Read(make([]byte, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
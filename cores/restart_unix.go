@@ -0,0 +1,7 @@
+//go:build !windows
+
+package cores
+
+import "syscall"
+
+var defaultRestartSignal = syscall.SIGUSR2
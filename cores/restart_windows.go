@@ -0,0 +1,8 @@
+//go:build windows
+
+package cores
+
+import "os"
+
+// windows can not hand over listeners to a new process, graceful restart is disabled
+var defaultRestartSignal os.Signal
@@ -0,0 +1,269 @@
+package cores
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-puzzles/puzzles/perror"
+)
+
+const (
+	PuzzlePending  = "pending"
+	PuzzleWaiting  = "waiting"
+	PuzzleRunning  = "running"
+	PuzzleFinished = "finished"
+	PuzzleFailed   = "failed"
+	PuzzleStopped  = "stopped"
+)
+
+// PuzzleInfo describes the state of a registered puzzle
+type PuzzleInfo struct {
+	Name      string   `json:"name"`
+	State     string   `json:"state"`
+	Ready     bool     `json:"ready"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// WorkerInfo describes the state of a registered worker
+type WorkerInfo struct {
+	Name          string `json:"name"`
+	Kind          string `json:"kind"`
+	RestartPolicy string `json:"restartPolicy"`
+	Restarts      int64  `json:"restarts"`
+	LastError     string `json:"lastError,omitempty"`
+}
+
+// CronInfo describes the state of a cron worker
+type CronInfo struct {
+	Name    string     `json:"name"`
+	Spec    string     `json:"spec"`
+	Prev    *time.Time `json:"prev,omitempty"`
+	Next    *time.Time `json:"next,omitempty"`
+	Running bool       `json:"running"`
+	Paused  bool       `json:"paused"`
+}
+
+type puzzleStates struct {
+	mu     sync.Mutex
+	states map[string]string
+	errs   map[string]error
+}
+
+func (o *Options) setPuzzleState(name, state string, err error) {
+	o.puzzleStates.mu.Lock()
+	defer o.puzzleStates.mu.Unlock()
+
+	o.puzzleStates.states[name] = state
+	o.puzzleStates.errs[name] = err
+}
+
+func (o *Options) isReady(name string) bool {
+	select {
+	case <-o.readySignal(name).ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Puzzles returns the state of the registered puzzles sorted by name
+func (o *Options) Puzzles() []PuzzleInfo {
+	o.puzzleStates.mu.Lock()
+	defer o.puzzleStates.mu.Unlock()
+
+	infos := make([]PuzzleInfo, 0, len(o.puzzles))
+	for name, p := range o.puzzles {
+		info := PuzzleInfo{
+			Name:      name,
+			State:     PuzzlePending,
+			Ready:     o.isReady(name),
+			DependsOn: puzzleDependencies(p),
+		}
+		if state, ok := o.puzzleStates.states[name]; ok {
+			info.State = state
+		}
+		if err := o.puzzleStates.errs[name]; err != nil {
+			info.Error = err.Error()
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Workers returns the state of the registered workers in registration order, followed by the supervisors
+func (o *Options) Workers() []WorkerInfo {
+	infos := make([]WorkerInfo, 0, len(o.workers)+len(o.supervisors))
+	for _, worker := range o.workers {
+		var (
+			b    *base
+			kind string
+		)
+		switch w := worker.(type) {
+		case *simpleWorker:
+			b, kind = w.base, "worker"
+			if w.daemon {
+				kind = "daemon"
+			}
+		case *cronWorker:
+			b, kind = w.base, "cron"
+		default:
+			continue
+		}
+
+		infos = append(infos, workerInfo(b, kind))
+	}
+	for _, s := range o.supervisors {
+		infos = append(infos, workerInfo(s.base, s.kind))
+	}
+	return infos
+}
+
+func workerInfo(b *base, kind string) WorkerInfo {
+	info := WorkerInfo{
+		Name:          b.name,
+		Kind:          kind,
+		RestartPolicy: b.restart.policy.String(),
+		Restarts:      b.status.Restarts(),
+	}
+	if err := b.status.LastError(); err != nil {
+		info.LastError = err.Error()
+	}
+	return info
+}
+
+// CronJobs returns the state of the cron workers once the service is started
+func (o *Options) CronJobs() []CronInfo {
+	c := o.core
+	if c == nil {
+		return nil
+	}
+	cr, workers := c.mountedCron()
+	if cr == nil {
+		return nil
+	}
+
+	infos := make([]CronInfo, 0, len(workers))
+	for _, w := range workers {
+		entry := cr.Entry(w.entryID)
+
+		w.mu.Lock()
+		running := w.running
+		w.mu.Unlock()
+
+		info := CronInfo{
+			Name:    w.name,
+			Spec:    w.cron,
+			Running: running,
+			Paused:  w.paused.Load(),
+		}
+		if !entry.Prev.IsZero() {
+			info.Prev = &entry.Prev
+		}
+		if !entry.Next.IsZero() {
+			info.Next = &entry.Next
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (o *Options) cronWorker(name string) (*CoreService, *cronWorker, error) {
+	c := o.core
+	if c != nil {
+		_, workers := c.mountedCron()
+		for _, w := range workers {
+			if w.name == name {
+				return c, w, nil
+			}
+		}
+	}
+	return nil, nil, perror.PackError(perror.CodeNotFound, fmt.Sprintf("cron job %s not found", name))
+}
+
+// TriggerCron runs the named cron job now, even if it is paused
+func (o *Options) TriggerCron(name string) error {
+	c, w, err := o.cronWorker(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.triggerCron(w)
+	return err
+}
+
+// RunCron runs the named cron job now like TriggerCron and waits until the job is no longer running,
+// the run may have been skipped or queued behind the current one according to the overlap policy
+func (o *Options) RunCron(ctx context.Context, name string) error {
+	c, w, err := o.cronWorker(name)
+	if err != nil {
+		return err
+	}
+
+	done, err := c.triggerCron(w)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return perror.WrapError(500, ctx.Err(), fmt.Sprintf("run cron job %s", name))
+	}
+
+	for {
+		w.mu.Lock()
+		running, runDone := w.running, w.runDone
+		w.mu.Unlock()
+		if !running {
+			return nil
+		}
+
+		select {
+		case <-runDone:
+		case <-ctx.Done():
+			return perror.WrapError(500, ctx.Err(), fmt.Sprintf("run cron job %s", name))
+		}
+	}
+}
+
+func (c *CoreService) triggerCron(w *cronWorker) (<-chan struct{}, error) {
+	if !c.addCronRun() {
+		return nil, perror.PackError(500, fmt.Sprintf("cron job %s can not run, the service is stopping", w.name))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer c.cronRuns.Done()
+		defer close(done)
+		c.runCronWorker(w, time.Now().Truncate(time.Second))
+	}()
+	return done, nil
+}
+
+// PauseCron stops the named cron job from running on its schedule until ResumeCron is called
+func (o *Options) PauseCron(name string) error {
+	_, w, err := o.cronWorker(name)
+	if err != nil {
+		return err
+	}
+
+	w.paused.Store(true)
+	return nil
+}
+
+// ResumeCron makes the named cron job run on its schedule again
+func (o *Options) ResumeCron(name string) error {
+	_, w, err := o.cronWorker(name)
+	if err != nil {
+		return err
+	}
+
+	w.paused.Store(false)
+	return nil
+}
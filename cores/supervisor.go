@@ -0,0 +1,213 @@
+package cores
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/go-puzzles/puzzles/plog"
+)
+
+// RestartPolicy decides whether a worker is run again after it returned
+type RestartPolicy int
+
+const (
+	// RestartNever runs the worker only once
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure runs the worker again when it returned an error or panicked
+	RestartOnFailure
+	// RestartAlways runs the worker again whenever it returned
+	RestartAlways
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultMaxRestartBackoff = 30 * time.Second
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	default:
+		return "never"
+	}
+}
+
+type restartOptions struct {
+	policy      RestartPolicy
+	maxRestarts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// WithRestartPolicy sets when the worker is restarted after it returned, the default is RestartNever.
+func WithRestartPolicy(policy RestartPolicy) WorkerOption {
+	return func(b *base) {
+		b.restart.policy = policy
+	}
+}
+
+// WithMaxRestarts limits how many times the worker is restarted, n <= 0 means unlimited.
+func WithMaxRestarts(n int) WorkerOption {
+	return func(b *base) {
+		b.restart.maxRestarts = n
+	}
+}
+
+// WithRestartBackoff sets the delay before the first restart, the delay doubles on
+// each consecutive restart up to maxDelay. A random jitter is applied to every delay.
+func WithRestartBackoff(initial, maxDelay time.Duration) WorkerOption {
+	return func(b *base) {
+		b.restart.backoff = initial
+		b.restart.maxBackoff = maxDelay
+	}
+}
+
+// workerStatus keeps track of the runs of a worker
+type workerStatus struct {
+	restarts atomic.Int64
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func (s *workerStatus) setLastErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+}
+
+// Restarts returns how many times the worker has been restarted
+func (s *workerStatus) Restarts() int64 {
+	return s.restarts.Load()
+}
+
+// LastError returns the error returned by the last failed run of the worker
+func (s *workerStatus) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Supervisor runs a worker which is not mounted by the cores service, e.g. a worker which
+// only runs while some condition holds, with the restart policy of its worker options.
+// It is listed by Options.Workers.
+type Supervisor struct {
+	*base
+	kind string
+}
+
+// NewSupervisor creates a supervised worker reported by Workers under name with the given kind
+func (o *Options) NewSupervisor(kind, name string, fn WorkerFunc, opts ...WorkerOption) *Supervisor {
+	s := &Supervisor{base: newBase(name, fn, opts...), kind: kind}
+	o.supervisors = append(o.supervisors, s)
+	return s
+}
+
+func (s *Supervisor) Name() string {
+	return s.name
+}
+
+// Run runs the worker and restarts it according to its restart policy until ctx is done
+func (s *Supervisor) Run(ctx context.Context) error {
+	return s.supervise(ctx)
+}
+
+// safeRun calls fn, converting a panic into a perror error carrying the stack trace
+func safeRun(ctx context.Context, name string, fn WorkerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = perror.PackError(500, fmt.Sprintf("worker: %v panic: %v\n%s", name, r, debug.Stack()))
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// maxBackoffDelay returns the max delay between restarts
+func (o restartOptions) maxBackoffDelay() time.Duration {
+	if o.maxBackoff <= 0 {
+		return defaultMaxRestartBackoff
+	}
+	return o.maxBackoff
+}
+
+// nextAttempt returns the backoff attempt of the next restart of a worker which ran for ran,
+// a worker which ran longer than the max backoff is considered recovered
+func (o restartOptions) nextAttempt(attempt int, ran time.Duration) int {
+	if ran > o.maxBackoffDelay() {
+		return 0
+	}
+	return attempt
+}
+
+// backoffDelay returns the delay before the attempt-th restart with a jitter in [d/2, d]
+func (o restartOptions) backoffDelay(attempt int) time.Duration {
+	d, maxDelay := o.backoff, o.maxBackoffDelay()
+	if d <= 0 {
+		d = defaultRestartBackoff
+	}
+
+	for i := 0; i < attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, maxDelay)
+
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int64N(half+1))
+}
+
+// supervise runs the worker and restarts it according to its restart policy until ctx is done.
+// It returns the error of the last run when the worker is not restarted anymore.
+func (b *base) supervise(ctx context.Context) error {
+	attempt := 0
+	for {
+		start := time.Now()
+		err := safeRun(ctx, b.name, b.fn)
+		if err != nil && !isCanceled(err) {
+			b.status.setLastErr(err)
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		switch {
+		case b.restart.policy == RestartNever:
+			return err
+		case b.restart.policy == RestartOnFailure && err == nil:
+			return nil
+		case b.restart.maxRestarts > 0 && int(b.status.Restarts()) >= b.restart.maxRestarts:
+			plog.Errorc(ctx, "worker: %v reached max restarts: %d", b.name, b.restart.maxRestarts)
+			return err
+		}
+
+		attempt = b.restart.nextAttempt(attempt, time.Since(start))
+		delay := b.restart.backoffDelay(attempt)
+		attempt++
+
+		if err != nil {
+			plog.Warnc(ctx, "worker: %v exited with error: %v, restarting in %v", b.name, err, delay)
+		} else {
+			plog.Infoc(ctx, "worker: %v exited, restarting in %v", b.name, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		b.status.restarts.Add(1)
+	}
+}
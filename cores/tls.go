@@ -0,0 +1,106 @@
+package cores
+
+import (
+	"crypto/tls"
+	"slices"
+	"time"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/go-puzzles/puzzles/plog"
+	"github.com/go-puzzles/puzzles/ptls"
+)
+
+type tlsOptions struct {
+	certFile       string
+	keyFile        string
+	clientCA       string
+	reloadInterval time.Duration
+}
+
+type TLSOption func(*tlsOptions)
+
+// WithClientCA requires the clients to present a certificate signed by the CA in caFile.
+func WithClientCA(caFile string) TLSOption {
+	return func(o *tlsOptions) {
+		o.clientCA = caFile
+	}
+}
+
+// WithTLSReloadInterval sets how often the certificate files are checked for changes.
+func WithTLSReloadInterval(interval time.Duration) TLSOption {
+	return func(o *tlsOptions) {
+		o.reloadInterval = interval
+	}
+}
+
+// WithTLS terminates TLS on the main listener in front of the grpc and http servers.
+// h2 and http/1.1 are negotiated through ALPN, the certificate files are reloaded when they change.
+// Once grpc is served, h2 is only negotiated with the clients not offering http/1.1.
+func WithTLS(certFile, keyFile string, opts ...TLSOption) ServiceOption {
+	return func(o *Options) {
+		t := &tlsOptions{
+			certFile: certFile,
+			keyFile:  keyFile,
+		}
+
+		for _, opt := range opts {
+			opt(t)
+		}
+
+		o.tls = t
+	}
+}
+
+func (c *CoreService) listenTLS() error {
+	t := c.opts.tls
+	if t == nil || c.listener == nil {
+		return nil
+	}
+
+	var opts []ptls.ReloaderOption
+	if t.clientCA != "" {
+		opts = append(opts, ptls.WithCA(t.clientCA))
+	}
+	if t.reloadInterval > 0 {
+		opts = append(opts, ptls.WithCheckInterval(t.reloadInterval))
+	}
+
+	reloader, err := ptls.NewReloader(t.certFile, t.keyFile, opts...)
+	if err != nil {
+		return perror.WrapError(500, err, "load tls certificate")
+	}
+
+	c.opts.TLSConfig = c.negotiateProtocol(reloader.ServerConfig(t.clientCA != ""))
+	c.listener = tls.NewListener(c.listener, c.opts.TLSConfig)
+
+	plog.Debugf("TLS enabled. Cert=%s MutualTLS=%v", t.certFile, t.clientCA != "")
+	return nil
+}
+
+// negotiateProtocol makes the clients offering http/1.1 use it once grpc is served on the main listener,
+// as all the h2 connections are routed to the grpc server then. The grpc clients only offer h2.
+func (c *CoreService) negotiateProtocol(base *tls.Config) *tls.Config {
+	getConfig := base.GetConfigForClient
+
+	cfg := base.Clone()
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		conf := base
+		if getConfig != nil {
+			clientConf, err := getConfig(hello)
+			if err != nil {
+				return nil, err
+			}
+			if clientConf != nil {
+				conf = clientConf
+			}
+		}
+
+		if c.cmux == nil || !c.cmux.grpcServed.Load() || !slices.Contains(hello.SupportedProtos, "http/1.1") {
+			return conf, nil
+		}
+		conf = conf.Clone()
+		conf.NextProtos = []string{"http/1.1"}
+		return conf, nil
+	}
+	return cfg
+}
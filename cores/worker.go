@@ -7,10 +7,10 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/go-puzzles/puzzles/cores/health"
 	"github.com/go-puzzles/puzzles/perror"
 	"github.com/go-puzzles/puzzles/plog"
 	"github.com/pkg/errors"
-	"github.com/robfig/cron/v3"
 )
 
 type WorkerFunc func(ctx context.Context) error
@@ -23,73 +23,91 @@ type Worker interface {
 type base struct {
 	name string
 	fn   WorkerFunc
+
+	stopPriority int
+	stopTimeout  time.Duration
+
+	healthCheck *healthCheck
+
+	restart restartOptions
+	status  workerStatus
 }
 
-type simpleWorker struct {
-	*base
-	daemon bool
+type WorkerOption func(*base)
+
+// WithWorkerStopPriority sets the order in which the worker is stopped during graceful shutdown.
+// Lower priorities are stopped first, the default is StopPriorityWorker.
+func WithWorkerStopPriority(priority int) WorkerOption {
+	return func(b *base) {
+		b.stopPriority = priority
+	}
 }
 
-func (w *simpleWorker) Name() string {
-	return w.name
+// WithWorkerStopTimeout sets how long the worker may take to drain before it is force closed.
+func WithWorkerStopTimeout(timeout time.Duration) WorkerOption {
+	return func(b *base) {
+		b.stopTimeout = timeout
+	}
 }
 
-func (w *simpleWorker) Fn(ctx context.Context) error {
-	return w.fn(ctx)
+// WithWorkerHealthCheck registers a checker reporting the health of the worker under the worker name.
+func WithWorkerHealthCheck(fn health.CheckFunc, opts ...health.CheckerOption) WorkerOption {
+	return func(b *base) {
+		b.healthCheck = &healthCheck{fn: fn, opts: opts}
+	}
 }
 
-type cronWorker struct {
+func newBase(name string, fn WorkerFunc, opts ...WorkerOption) *base {
+	b := &base{
+		name:         name,
+		fn:           fn,
+		stopPriority: StopPriorityWorker,
+		stopTimeout:  defaultStopTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+type simpleWorker struct {
 	*base
-	cron    string
-	running bool
+	daemon bool
 }
 
-func (w *cronWorker) Name() string {
+func (w *simpleWorker) Name() string {
 	return w.name
 }
 
-func (w *cronWorker) Fn(ctx context.Context) error {
+func (w *simpleWorker) Fn(ctx context.Context) error {
 	return w.fn(ctx)
 }
 
-func withSimpleWorker(name string, daemon bool, fn WorkerFunc) ServiceOption {
+func withSimpleWorker(name string, daemon bool, fn WorkerFunc, opts ...WorkerOption) ServiceOption {
 	return func(cs *Options) {
 		cs.workers = append(cs.workers, &simpleWorker{
-			base: &base{
-				name: name,
-				fn:   fn,
-			},
+			base:   newBase(name, fn, opts...),
 			daemon: daemon,
 		})
 	}
 }
 
-func WithWorker(fn WorkerFunc) ServiceOption {
-	return withSimpleWorker(funcName(fn), false, fn)
+func WithWorker(fn WorkerFunc, opts ...WorkerOption) ServiceOption {
+	return withSimpleWorker(funcName(fn), false, fn, opts...)
 }
 
-func WithNameWorker(name string, fn WorkerFunc) ServiceOption {
-	return withSimpleWorker(name, false, fn)
+func WithNameWorker(name string, fn WorkerFunc, opts ...WorkerOption) ServiceOption {
+	return withSimpleWorker(name, false, fn, opts...)
 }
 
-func WithDaemonWorker(fn WorkerFunc) ServiceOption {
-	return withSimpleWorker(funcName(fn), true, fn)
+func WithDaemonWorker(fn WorkerFunc, opts ...WorkerOption) ServiceOption {
+	return withSimpleWorker(funcName(fn), true, fn, opts...)
 }
 
-func WithDaemonNameWorker(name string, fn WorkerFunc) ServiceOption {
-	return withSimpleWorker(name, true, fn)
-}
-
-func WithCronWorker(cron string, fn WorkerFunc) ServiceOption {
-	return func(cs *Options) {
-		cs.workers = append(cs.workers, &cronWorker{
-			base: &base{
-				name: funcName(fn),
-				fn:   fn,
-			},
-			cron: cron,
-		})
-	}
+func WithDaemonNameWorker(name string, fn WorkerFunc, opts ...WorkerOption) ServiceOption {
+	return withSimpleWorker(name, true, fn, opts...)
 }
 
 func funcName(i any) string {
@@ -122,7 +140,7 @@ func (c *CoreService) mountSimpleWorker(worker *simpleWorker) {
 			}()
 		}
 
-		if err := worker.fn(c); err != nil && !errors.Is(err, context.Canceled) {
+		if err := worker.supervise(c); err != nil && !isCanceled(err) {
 			plog.Errorc(c, "worker: %v run error: %v", worker.name, err)
 			return perror.WrapError(500, err, fmt.Sprintf("simpleWorker: %v run failed", worker.name))
 		}
@@ -130,61 +148,22 @@ func (c *CoreService) mountSimpleWorker(worker *simpleWorker) {
 	}
 
 	mf := mountFn{
-		fn:     fn,
-		name:   worker.name,
-		daemon: worker.daemon,
+		fn:           fn,
+		name:         worker.name,
+		daemon:       worker.daemon,
+		stopPriority: worker.stopPriority,
+		stopTimeout:  worker.stopTimeout,
 	}
 
 	c.mountFns = append(c.mountFns, mf)
 }
 
-func (c *CoreService) mountCronWorker(worker *cronWorker) {
-	if c.cron == nil {
-		c.cron = cron.New()
-		c.mountFns = append(c.mountFns, mountFn{
-			fn: func(ctx context.Context) error {
-				defer c.cron.Stop()
-				err := waitContext(ctx, true, func(_ context.Context) error {
-					c.cron.Run()
-					return nil
-				})
-
-				if err != nil {
-					plog.Errorc(ctx, "Run cron error: %v", err)
-					return errors.Wrap(err, "Run cron error")
-				}
-				return nil
-			},
-			name:   "CronWorker",
-			daemon: true,
-		})
-	}
-
-	sched, err := cron.ParseStandard(worker.cron)
-	if err != nil {
-		plog.Fatalf("cron worker cron invalid. err: %v", err)
-	}
-
-	fn := func() {
-		ctx := plog.With(c.ctx, "Worker", worker.name)
-		defer plog.Debugc(ctx, "Cron Worker: %v Next scheduler time: %v", worker.name, sched.Next(time.Now()))
-
-		err := waitContext(ctx, false, func(ctx context.Context) error {
-			if worker.running {
-				return errors.New("job still running")
-			}
-			worker.running = true
-			defer func() { worker.running = false }()
-
-			return worker.fn(ctx)
-		})
-
-		if err != nil {
-			plog.Errorc(ctx, "Run cron worker: %v error: %v", worker.name, err)
-		}
+func (c *CoreService) registerWorkerHealth(b *base) {
+	if b.healthCheck == nil {
+		return
 	}
 
-	c.cron.AddFunc(worker.cron, fn)
+	c.opts.Health.Register(b.name, b.healthCheck.fn, b.healthCheck.opts...)
 }
 
 func (c *CoreService) wrapWorker() {
@@ -194,21 +173,32 @@ func (c *CoreService) wrapWorker() {
 			if w.name == "" {
 				w.name = plog.GetFuncName(w)
 			}
+			c.registerWorkerHealth(w.base)
 			c.mountSimpleWorker(w)
 		case *cronWorker:
 			if w.name == "" {
 				w.name = plog.GetFuncName(w)
 			}
+			c.registerWorkerHealth(w.base)
 			c.mountCronWorker(w)
 		default:
 			plog.Warnc(c.ctx, "Unknown worker type. worker: %v", plog.GetFuncName(w))
 		}
 	}
+
+	if c.cron != nil {
+		c.mountFns = append(c.mountFns, c.mountCron())
+	}
 }
 
-func waitContext(ctx context.Context, quitImmediately bool, fn func(context.Context) error) (err error) {
+var errForceClosed = errors.New("force closed")
+
+// waitContext runs fn and waits for it to return. Once ctx is done it returns
+// immediately if quitImmediately is set, otherwise fn has timeout to drain
+// before it is force closed.
+func waitContext(ctx context.Context, quitImmediately bool, timeout time.Duration, fn func(context.Context) error) (err error) {
 	defer func() {
-		if errors.Is(err, context.Canceled) {
+		if isCanceled(err) {
 			err = nil
 		}
 	}()
@@ -227,15 +217,36 @@ func waitContext(ctx context.Context, quitImmediately bool, fn func(context.Cont
 			return perror.WrapError(500, ctx.Err(), "quit immediately")
 		}
 
-		ticket := time.NewTicker(time.Second * 5)
+		if timeout <= 0 {
+			timeout = defaultStopTimeout
+		}
+		ticket := time.NewTicker(timeout)
 		defer ticket.Stop()
 
 		select {
 		case err = <-stop:
+			if err == nil || isCanceled(err) {
+				return nil
+			}
 			return perror.WrapError(500, err, "stop")
 		case <-ticket.C:
 			plog.Infoc(ctx, "Force closing worker")
-			return perror.WrapError(500, ctx.Err(), "Force close")
+			return perror.WrapError(500, errForceClosed, "Force close")
+		}
+	}
+}
+
+// isCanceled reports whether err, or the cause of a perror wrapping it, is context.Canceled
+func isCanceled(err error) bool {
+	for err != nil {
+		if errors.Is(err, context.Canceled) {
+			return true
+		}
+		ce, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
 		}
+		err = ce.Cause()
 	}
+	return false
 }
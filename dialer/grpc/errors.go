@@ -0,0 +1,41 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/go-puzzles/puzzles/perror/pstatus"
+	"google.golang.org/grpc"
+)
+
+// unaryClientErrorInterceptor converts the statuses returned by the server to perror.ErrorR
+func unaryClientErrorInterceptor() func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return pstatus.FromError(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+func streamClientErrorInterceptor() func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, pstatus.FromError(err)
+		}
+		return &errorClientStream{ClientStream: cs}, nil
+	}
+}
+
+type errorClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *errorClientStream) SendMsg(m any) error {
+	return pstatus.FromError(s.ClientStream.SendMsg(m))
+}
+
+func (s *errorClientStream) RecvMsg(m any) error {
+	return pstatus.FromError(s.ClientStream.RecvMsg(m))
+}
+
+func (s *errorClientStream) CloseSend() error {
+	return pstatus.FromError(s.ClientStream.CloseSend())
+}
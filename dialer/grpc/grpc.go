@@ -60,7 +60,8 @@ func DialGrpcWithTagContext(ctx context.Context, service string, tag string, opt
 // }
 
 func dialGrpcWithTagContext(ctx context.Context, service, tag string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
-	options := append(opts, defaultGRPCDialOptions()...)
+	// the options given by the caller take precedence over the defaults, e.g. WithTLS
+	options := append(defaultGRPCDialOptions(), opts...)
 
 	address := discover.GetServiceFinder().GetAddressWithTag(service, tag)
 
@@ -81,7 +82,7 @@ func defaultGRPCDialOptions() []grpc.DialOption {
 	return []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024 * 1024 * 16)),
-		grpc.WithChainUnaryInterceptor(unaryClientLoggerInterceptor()),
-		grpc.WithChainStreamInterceptor(streamClientLoggerInterceptor()),
+		grpc.WithChainUnaryInterceptor(unaryClientLoggerInterceptor(), unaryClientErrorInterceptor()),
+		grpc.WithChainStreamInterceptor(streamClientLoggerInterceptor(), streamClientErrorInterceptor()),
 	}
 }
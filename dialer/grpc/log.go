@@ -10,40 +10,77 @@ package grpc
 
 import (
 	"context"
+	"slices"
+	"sync/atomic"
 
 	"github.com/go-puzzles/puzzles/plog"
+	"github.com/go-puzzles/puzzles/plog/rpclog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
 )
 
+var logConfig atomic.Pointer[rpclog.Config]
+
+func init() {
+	logConfig.Store(rpclog.NewConfig())
+}
+
+// SetLogOptions configures the logs of the calls made by all the connections, see rpclog
+func SetLogOptions(opts ...rpclog.Option) {
+	logConfig.Store(rpclog.NewConfig(opts...))
+}
+
+// peerAddr returns the address of the server, the target of the connection until the call knows it
+func peerAddr(p *peer.Peer, cc *grpc.ClientConn) string {
+	if p.Addr != nil {
+		return p.Addr.String()
+	}
+	return cc.Target()
+}
+
 func unaryClientLoggerInterceptor() func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		if !plog.IsDebug() {
+		conf := logConfig.Load()
+		if !plog.IsDebug() && conf.SlowThreshold == 0 {
 			return invoker(ctx, method, req, reply, cc, opts...)
 		}
+
+		var p peer.Peer
 		td := plog.TimeFuncDuration()
-		err := invoker(ctx, method, req, reply, cc, opts...)
-		if err != nil {
-			plog.Debugc(ctx, "Failed to invoke method %s invoke_time=%s invoke_err=%s", method, td(), err)
-		} else {
-			plog.Debugc(ctx, "Succeed to invoke method %s invoke_time=%s", method, td())
+		err := invoker(ctx, method, req, reply, cc, append(slices.Clip(opts), grpc.Peer(&p))...)
+		duration := td()
+
+		call := rpclog.Call{
+			Method:   method,
+			Duration: duration,
+			Err:      err,
+			Peer:     peerAddr(&p, cc),
+			Req:      req,
+		}
+		if err == nil {
+			call.Resp = reply
 		}
+		conf.Log(ctx, "invoke", call)
 		return err
 	}
 }
 
 func streamClientLoggerInterceptor() func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-		if !plog.IsDebug() {
+		conf := logConfig.Load()
+		if !plog.IsDebug() && conf.SlowThreshold == 0 {
 			return streamer(ctx, desc, cc, method, opts...)
 		}
 
+		var p peer.Peer
 		td := plog.TimeFuncDuration()
-		cs, err := streamer(ctx, desc, cc, method, opts...)
-		if err != nil {
-			plog.Debugc(ctx, "Failed to invoke method %s invoke_time=%s invoke_err=%s", method, td(), err)
-		} else {
-			plog.Debugc(ctx, "Succeed to invoke method %s invoke_time=%s", method, td())
-		}
+		cs, err := streamer(ctx, desc, cc, method, append(slices.Clip(opts), grpc.Peer(&p))...)
+		conf.Log(ctx, "invoke", rpclog.Call{
+			Method:   method,
+			Duration: td(),
+			Err:      err,
+			Peer:     peerAddr(&p, cc),
+		})
 		return cs, err
 	}
 }
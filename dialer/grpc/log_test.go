@@ -0,0 +1,40 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-puzzles/puzzles/plog/rpclog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestUnaryClientLoggerInterceptor(t *testing.T) {
+	defer SetLogOptions()
+	interceptor := unaryClientLoggerInterceptor()
+
+	var got []grpc.CallOption
+	invoker := func(_ context.Context, _ string, _, _ any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+		got = opts
+		return nil
+	}
+
+	// the options set after the interceptor was built apply to its calls
+	SetLogOptions(rpclog.WithSlowThreshold(time.Hour))
+
+	cc, err := grpc.NewClient("passthrough:///test", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer cc.Close()
+
+	opts := make([]grpc.CallOption, 1, 2)
+	opts[0] = grpc.WaitForReady(true)
+	assert.Nil(t, interceptor(context.Background(), "/m", nil, nil, cc, invoker, opts...))
+	assert.Len(t, got, 2)
+
+	// the peer option is not written into the backing array of the caller
+	assert.Nil(t, opts[:2][1])
+}
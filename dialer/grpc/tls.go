@@ -0,0 +1,49 @@
+package grpc
+
+import (
+	"github.com/go-puzzles/puzzles/ptls"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type tlsOptions struct {
+	serverName string
+}
+
+type TLSOption func(*tlsOptions)
+
+// WithServerName sets the name the server certificate is verified against,
+// the host of the dialed address is used by default.
+func WithServerName(name string) TLSOption {
+	return func(o *tlsOptions) {
+		o.serverName = name
+	}
+}
+
+// WithTLS secures the connection with TLS, the server is verified against the CA in caFile,
+// or against the system roots if caFile is empty.
+func WithTLS(caFile string, opts ...TLSOption) (grpc.DialOption, error) {
+	return WithMutualTLS("", "", caFile, opts...)
+}
+
+// WithMutualTLS secures the connection with TLS and presents the client certificate to the server.
+// The certificate files are reloaded when they change.
+func WithMutualTLS(certFile, keyFile, caFile string, opts ...TLSOption) (grpc.DialOption, error) {
+	o := &tlsOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var reloaderOpts []ptls.ReloaderOption
+	if caFile != "" {
+		reloaderOpts = append(reloaderOpts, ptls.WithCA(caFile))
+	}
+
+	reloader, err := ptls.NewReloader(certFile, keyFile, reloaderOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "load tls certificate")
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(reloader.ClientConfig(o.serverName))), nil
+}
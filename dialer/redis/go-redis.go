@@ -10,9 +10,18 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// DialGoRedisClient dials a client reporting its pool stats, close it with CloseGoRedisClient
 func DialGoRedisClient(opts *redis.Options) *redis.Client {
 	opts.Dialer = consulGoRedisDial
-	return redis.NewClient(opts)
+	cli := redis.NewClient(opts)
+	collectGoRedisPool(cli)
+	return cli
+}
+
+// CloseGoRedisClient stops reporting the pool stats of cli and closes it
+func CloseGoRedisClient(cli *redis.Client) error {
+	stopCollecting(cli)
+	return cli.Close()
 }
 
 func consulGoRedisDial(ctx context.Context, network, addr string) (net.Conn, error) {
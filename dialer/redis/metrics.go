@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-puzzles/puzzles/pmetrics"
+	redigo "github.com/gomodule/redigo/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var (
+	metricsRegistry atomic.Pointer[pmetrics.Registry]
+
+	// collectors holds the func removing the pool stats hook of every dialed client by client
+	collectors sync.Map
+)
+
+// SetMetricsRegistry records the pool stats of the clients dialed afterwards into r instead of
+// pmetrics.Default(), use the registry given to metricspuzzle.WithRegistry to expose them on /metrics.
+func SetMetricsRegistry(r *pmetrics.Registry) {
+	metricsRegistry.Store(r)
+}
+
+func poolConnections() (*pmetrics.Registry, *pmetrics.Gauge) {
+	r := metricsRegistry.Load()
+	if r == nil {
+		r = pmetrics.Default()
+	}
+
+	return r, r.NewGauge(
+		"redis_pool_connections",
+		"Number of connections in the redis pool by state.",
+		"client", "addr", "db", "state",
+	)
+}
+
+func collectGoRedisPool(cli *goredis.Client) {
+	opts := cli.Options()
+	addr, db := opts.Addr, strconv.Itoa(opts.DB)
+
+	r, gauge := poolConnections()
+	collectors.Store(cli, r.OnCollect(func() {
+		stats := cli.PoolStats()
+		gauge.Set(float64(stats.TotalConns), "goredis", addr, db, "total")
+		gauge.Set(float64(stats.IdleConns), "goredis", addr, db, "idle")
+	}))
+}
+
+func collectRedigoPool(pool *redigo.Pool, addr string, db int) {
+	dbs := strconv.Itoa(db)
+
+	r, gauge := poolConnections()
+	collectors.Store(pool, r.OnCollect(func() {
+		stats := pool.Stats()
+		gauge.Set(float64(stats.ActiveCount), "redigo", addr, dbs, "total")
+		gauge.Set(float64(stats.IdleCount), "redigo", addr, dbs, "idle")
+	}))
+}
+
+func stopCollecting(key any) {
+	if unregister, ok := collectors.LoadAndDelete(key); ok {
+		unregister.(func())()
+	}
+}
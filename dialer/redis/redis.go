@@ -8,12 +8,21 @@ import (
 	"github.com/gomodule/redigo/redis"
 )
 
+// DialRedisPool dials a pool reporting its stats, close it with CloseRedisPool
 func DialRedisPool(addr string, db int, maxIdle int, password ...string) *redis.Pool {
-	return &redis.Pool{
+	pool := &redis.Pool{
 		MaxIdle:     maxIdle,
 		IdleTimeout: 300 * time.Second,
 		Dial:        consulRedisDial(addr, db, password...),
 	}
+	collectRedigoPool(pool, addr, db)
+	return pool
+}
+
+// CloseRedisPool stops reporting the stats of pool and closes it
+func CloseRedisPool(pool *redis.Pool) error {
+	stopCollecting(pool)
+	return pool.Close()
 }
 
 func consulRedisDial(addr string, db int, password ...string) func() (redis.Conn, error) {
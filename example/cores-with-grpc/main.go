@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/go-puzzles/puzzles/cores"
+	gatewaypuzzle "github.com/go-puzzles/puzzles/cores/puzzles/gateway-puzzle"
 	grpcpuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpc-puzzle"
 	grpcuipuzzle "github.com/go-puzzles/puzzles/cores/puzzles/grpcui-puzzle"
 	"github.com/go-puzzles/puzzles/example/cores-with-grpc/examplepb"
@@ -23,6 +24,7 @@ func main() {
 
 	srv := cores.NewPuzzleCore(
 		grpcuipuzzle.WithCoreGrpcUI(),
+		gatewaypuzzle.WithCoreGateway(examplepb.RegisterExampleHelloServiceHandler),
 		grpcpuzzle.WithCoreGrpcPuzzle(func(srv *grpc.Server) {
 			examplepb.RegisterExampleHelloServiceServer(srv, example)
 			testpb.RegisterExampleHelloServiceServer(srv, test)
@@ -41,11 +41,31 @@ const unlockScript = `
 	end
 	return 0`
 
+// Lua script for atomic lock renewal
+const renewScript = `
+	if redis.call('get', KEYS[1]) == ARGV[1] then
+		return redis.call('pexpire', KEYS[1], ARGV[2])
+	end
+	return 0`
+
 type PuzzleRedisClient struct {
 	*redis.Client
 	locks sync.Map // stores lock values for validation
 }
 
+type lockEntry struct {
+	value    string
+	expireAt time.Time // zero if the lock never expires
+}
+
+func newLockEntry(value string, expiration time.Duration) lockEntry {
+	e := lockEntry{value: value}
+	if expiration > 0 {
+		e.expireAt = time.Now().Add(expiration)
+	}
+	return e
+}
+
 func NewRedisClient(addr string, db int) *PuzzleRedisClient {
 	c := redisDialer.DialGoRedisClient(&redis.Options{
 		Addr: addr,
@@ -64,6 +84,11 @@ func NewRedisClientWithAuth(addr string, db int, user, pwd string) *PuzzleRedisC
 	return &PuzzleRedisClient{Client: c}
 }
 
+// Close stops reporting the pool stats of the client and closes it
+func (c *PuzzleRedisClient) Close() error {
+	return redisDialer.CloseGoRedisClient(c.Client)
+}
+
 // TryLock attempts to acquire a distributed lock
 func (c *PuzzleRedisClient) TryLock(ctx context.Context, key string, expiration time.Duration) error {
 	value := fmt.Sprintf("%s:%d", c.getInstanceID(), time.Now().UnixNano())
@@ -77,10 +102,44 @@ func (c *PuzzleRedisClient) TryLock(ctx context.Context, key string, expiration
 		return fmt.Errorf("%w: %s", ErrLockAcquireFailed, key)
 	}
 
-	c.locks.Store(key, value)
+	c.pruneLocks()
+	c.locks.Store(key, newLockEntry(value, expiration))
+	return nil
+}
+
+// RenewLock extends the expiration of a lock held by this client
+func (c *PuzzleRedisClient) RenewLock(ctx context.Context, key string, expiration time.Duration) error {
+	entryI, exists := c.locks.Load(key)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrLockNotFound, key)
+	}
+	entry := entryI.(lockEntry)
+
+	result, err := c.Eval(ctx, renewScript, []string{key}, entry.value, expiration.Milliseconds()).Result()
+	if err != nil {
+		return err
+	}
+
+	if v, ok := result.(int64); !ok || v != 1 {
+		c.locks.Delete(key)
+		return fmt.Errorf("%w: %s", ErrLockNotFound, key)
+	}
+
+	c.locks.Store(key, newLockEntry(entry.value, expiration))
 	return nil
 }
 
+// pruneLocks forgets the locks which have expired without being unlocked
+func (c *PuzzleRedisClient) pruneLocks() {
+	now := time.Now()
+	c.locks.Range(func(key, entryI any) bool {
+		if entry := entryI.(lockEntry); !entry.expireAt.IsZero() && entry.expireAt.Before(now) {
+			c.locks.CompareAndDelete(key, entryI)
+		}
+		return true
+	})
+}
+
 // TryLockWithTimeout attempts to acquire a lock with a timeout
 func (c *PuzzleRedisClient) TryLockWithTimeout(ctx context.Context, key string, expiration, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
@@ -98,13 +157,13 @@ func (c *PuzzleRedisClient) TryLockWithTimeout(ctx context.Context, key string,
 
 // Unlock releases a distributed lock
 func (c *PuzzleRedisClient) Unlock(ctx context.Context, key string) error {
-	valueI, exists := c.locks.Load(key)
+	entryI, exists := c.locks.Load(key)
 	if !exists {
 		return fmt.Errorf("%w: %s", ErrLockNotFound, key)
 	}
-	value := valueI.(string)
+	entry := entryI.(lockEntry)
 
-	result, err := c.Eval(ctx, unlockScript, []string{key}, value).Result()
+	result, err := c.Eval(ctx, unlockScript, []string{key}, entry.value).Result()
 	if err != nil {
 		return err
 	}
@@ -118,6 +177,11 @@ func (c *PuzzleRedisClient) Unlock(ctx context.Context, key string) error {
 	return nil
 }
 
+// HealthCheck pings the redis server, it can be registered as a health checker
+func (c *PuzzleRedisClient) HealthCheck(ctx context.Context) error {
+	return c.Ping(ctx).Err()
+}
+
 // getInstanceID returns the unique identifier for current instance
 func (c *PuzzleRedisClient) getInstanceID() string {
 	hostname, _ := os.Hostname()
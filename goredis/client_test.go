@@ -131,6 +131,28 @@ func TestPuzzleRedisClient_TryLockWithTimeout(t *testing.T) {
 	})
 }
 
+func TestPuzzleRedisClient_RenewLock(t *testing.T) {
+	client := setupTestClient(t)
+	ctx := context.Background()
+	key := "test_renew_lock"
+
+	client.Del(ctx, key)
+	defer client.Del(ctx, key)
+
+	err := client.TryLock(ctx, key, time.Second)
+	assert.NoError(t, err)
+
+	err = client.RenewLock(ctx, key, 5*time.Second)
+	assert.NoError(t, err)
+
+	ttl, err := client.PTTL(ctx, key).Result()
+	assert.NoError(t, err)
+	assert.Greater(t, ttl, time.Second)
+
+	err = client.RenewLock(ctx, "non_existent_lock", time.Second)
+	assert.ErrorIs(t, err, ErrLockNotFound)
+}
+
 func TestPuzzleRedisClient_ConcurrentLock(t *testing.T) {
 	client := NewRedisClient("localhost:6379", 0)
 	ctx := context.Background()
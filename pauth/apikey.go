@@ -0,0 +1,35 @@
+package pauth
+
+import (
+	"context"
+	"crypto/sha256"
+)
+
+// DefaultAPIKeyHeader is the header carrying the api keys
+const DefaultAPIKeyHeader = "X-Api-Key"
+
+// APIKey authenticates the requests carrying one of keys in the header, DefaultAPIKeyHeader when empty
+func APIKey(header string, keys map[string]Principal) Authenticator {
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+
+	// the keys are looked up by hash so that the lookup time does not depend on the key
+	hashed := make(map[[sha256.Size]byte]Principal, len(keys))
+	for key, p := range keys {
+		hashed[sha256.Sum256([]byte(key))] = p
+	}
+
+	return AuthenticatorFunc(func(_ context.Context, h Header) (*Principal, error) {
+		key := h.Get(header)
+		if key == "" {
+			return nil, nil
+		}
+
+		p, ok := hashed[sha256.Sum256([]byte(key))]
+		if !ok {
+			return nil, ErrUnauthenticated
+		}
+		return &p, nil
+	})
+}
@@ -0,0 +1,198 @@
+package pauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-puzzles/puzzles/perror"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+
+	defaultRolesClaim = "roles"
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+type jwtAuth struct {
+	secret     []byte
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	rolesClaim string
+	now        func() time.Time
+}
+
+type JWTOption func(*jwtAuth)
+
+// WithJWTIssuer rejects the tokens not issued by issuer
+func WithJWTIssuer(issuer string) JWTOption {
+	return func(a *jwtAuth) {
+		a.issuer = issuer
+	}
+}
+
+// WithJWTAudience rejects the tokens not intended for audience
+func WithJWTAudience(audience string) JWTOption {
+	return func(a *jwtAuth) {
+		a.audience = audience
+	}
+}
+
+// WithJWTLeeway tolerates a clock skew of leeway when checking the expiration of the tokens
+func WithJWTLeeway(leeway time.Duration) JWTOption {
+	return func(a *jwtAuth) {
+		a.leeway = leeway
+	}
+}
+
+// WithJWTRolesClaim reads the roles from the claim, "roles" by default.
+// The claim is an array of strings or a space separated string.
+func WithJWTRolesClaim(claim string) JWTOption {
+	return func(a *jwtAuth) {
+		a.rolesClaim = claim
+	}
+}
+
+// JWT authenticates the requests carrying a bearer token signed with secret using HS256.
+// The subject of the principal is the "sub" claim. It panics if secret is empty,
+// any token signed with an empty key would be accepted.
+func JWT(secret []byte, opts ...JWTOption) Authenticator {
+	if len(secret) == 0 {
+		panic("pauth: empty jwt secret")
+	}
+
+	a := &jwtAuth{
+		secret:     secret,
+		rolesClaim: defaultRolesClaim,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *jwtAuth) Authenticate(_ context.Context, h Header) (*Principal, error) {
+	token, ok := strings.CutPrefix(h.Get(authorizationHeader), bearerPrefix)
+	if !ok {
+		return nil, nil
+	}
+
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Principal{Claims: claims}
+	p.Subject, _ = claims["sub"].(string)
+	switch roles := claims[a.rolesClaim].(type) {
+	case string:
+		p.Roles = strings.Fields(roles)
+	case []any:
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				p.Roles = append(p.Roles, s)
+			}
+		}
+	}
+	return p, nil
+}
+
+func invalidToken(reason string) error {
+	return perror.PackError(perror.CodeUnauthorized, "invalid token: "+reason)
+}
+
+func (a *jwtAuth) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalidToken("malformed header")
+	}
+	if header.Alg != "HS256" {
+		return nil, invalidToken("unsupported algorithm " + header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, sign(a.secret, parts[0]+"."+parts[1])) {
+		return nil, invalidToken("bad signature")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalidToken("malformed claims")
+	}
+
+	now := a.now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(a.leeway)) {
+		return nil, invalidToken("expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, invalidToken("not valid yet")
+	}
+	if a.issuer != "" && claims["iss"] != a.issuer {
+		return nil, invalidToken("bad issuer")
+	}
+	if a.audience != "" && !hasAudience(claims["aud"], a.audience) {
+		return nil, invalidToken("bad audience")
+	}
+	return claims, nil
+}
+
+func hasAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		return slices.Contains(aud, any(audience))
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func sign(secret []byte, data string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// SignJWT returns a HS256 token carrying claims, signed with secret
+func SignJWT(secret []byte, claims map[string]any) (string, error) {
+	if len(secret) == 0 {
+		return "", perror.PackError(perror.CodeInvalidInput, "empty jwt secret")
+	}
+
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", perror.WrapError(perror.CodeInvalidInput, err, "marshal claims")
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sign(secret, unsigned)), nil
+}
@@ -0,0 +1,173 @@
+// Package pauth authenticates the requests of a service and checks the roles of the callers.
+//
+// An Authenticator turns the request headers, or the grpc metadata, into a Principal stored in
+// the request context. The grpc puzzle and pgin wrap a Policy into interceptors and middlewares.
+package pauth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-puzzles/puzzles/perror"
+)
+
+// HealthMethods is the grpc health service, public by default so that the probes keep working
+const HealthMethods = "/grpc.health.v1.Health/*"
+
+// Principal is the authenticated caller
+type Principal struct {
+	Subject string
+	Roles   []string
+	// Claims are the raw claims of a token, nil for the api keys
+	Claims map[string]any
+}
+
+// HasRole reports whether the principal has one of roles
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the request, false when the caller is anonymous
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// RequireRoles checks that the principal of ctx has one of roles
+func RequireRoles(ctx context.Context, roles ...string) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if len(roles) > 0 && !p.HasRole(roles...) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+var (
+	ErrUnauthenticated = perror.PackError(perror.CodeUnauthorized, "unauthenticated")
+	ErrForbidden       = perror.PackError(http.StatusForbidden, "permission denied")
+)
+
+// Header reads the request headers or the grpc metadata, the keys are case insensitive
+type Header interface {
+	Get(key string) string
+}
+
+// Authenticator authenticates the requests. It returns a nil principal and no error when
+// the request does not carry its kind of credentials, and an error when they are invalid.
+type Authenticator interface {
+	Authenticate(ctx context.Context, h Header) (*Principal, error)
+}
+
+type AuthenticatorFunc func(ctx context.Context, h Header) (*Principal, error)
+
+func (fn AuthenticatorFunc) Authenticate(ctx context.Context, h Header) (*Principal, error) {
+	return fn(ctx, h)
+}
+
+// Chain tries the authenticators in order and returns the first principal
+func Chain(auths ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, h Header) (*Principal, error) {
+		for _, auth := range auths {
+			p, err := auth.Authenticate(ctx, h)
+			if err != nil || p != nil {
+				return p, err
+			}
+		}
+		return nil, nil
+	})
+}
+
+type Option func(*Policy)
+
+// WithPublic lets the anonymous callers use the methods, or the routes.
+// A trailing "*" matches all the methods with the prefix, like "/package.Service/*".
+func WithPublic(methods ...string) Option {
+	return func(p *Policy) {
+		p.public = append(p.public, methods...)
+	}
+}
+
+// WithRoles restricts the method, or the route, to the callers having one of roles.
+// A trailing "*" matches all the methods with the prefix.
+func WithRoles(method string, roles ...string) Option {
+	return func(p *Policy) {
+		p.roles = append(p.roles, methodRoles{method: method, roles: roles})
+	}
+}
+
+type methodRoles struct {
+	method string
+	roles  []string
+}
+
+// Policy authenticates the callers and checks their roles, per grpc full method name or gin route
+type Policy struct {
+	auth   Authenticator
+	public []string
+	roles  []methodRoles
+}
+
+func NewPolicy(auth Authenticator, opts ...Option) *Policy {
+	p := &Policy{
+		auth:   auth,
+		public: []string{HealthMethods},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Authorize authenticates the caller of method and returns ctx carrying its principal.
+// The errors are perror, ErrUnauthenticated or ErrForbidden.
+func (p *Policy) Authorize(ctx context.Context, method string, h Header) (context.Context, error) {
+	public := slices.ContainsFunc(p.public, func(pattern string) bool {
+		return match(pattern, method)
+	})
+
+	principal, err := p.auth.Authenticate(ctx, h)
+	if err != nil || principal == nil {
+		if public {
+			return ctx, nil
+		}
+		if _, ok := perror.AsErrorR(err); ok {
+			return ctx, err
+		}
+		if err != nil {
+			return ctx, perror.WrapError(perror.CodeUnauthorized, err, "unauthenticated")
+		}
+		return ctx, ErrUnauthenticated
+	}
+	ctx = WithPrincipal(ctx, principal)
+
+	for _, r := range p.roles {
+		if match(r.method, method) && !principal.HasRole(r.roles...) {
+			return ctx, ErrForbidden
+		}
+	}
+	return ctx, nil
+}
+
+func match(pattern, method string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(method, prefix)
+	}
+	return pattern == method
+}
@@ -0,0 +1,90 @@
+package pauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/stretchr/testify/assert"
+)
+
+var secret = []byte("secret")
+
+func bearer(t *testing.T, claims map[string]any) http.Header {
+	token, err := SignJWT(secret, claims)
+	assert.Nil(t, err)
+	return http.Header{"Authorization": []string{"Bearer " + token}}
+}
+
+func TestJWT(t *testing.T) {
+	auth := JWT(secret, WithJWTIssuer("puzzles"), WithJWTAudience("api"))
+	exp := time.Now().Add(time.Minute).Unix()
+
+	p, err := auth.Authenticate(context.Background(), bearer(t, map[string]any{
+		"sub": "alice", "roles": []string{"admin"}, "iss": "puzzles", "aud": []string{"api"}, "exp": exp,
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", p.Subject)
+	assert.True(t, p.HasRole("admin"))
+
+	for name, claims := range map[string]map[string]any{
+		"expired":  {"sub": "alice", "iss": "puzzles", "aud": "api", "exp": time.Now().Add(-time.Minute).Unix()},
+		"issuer":   {"sub": "alice", "iss": "other", "aud": "api"},
+		"audience": {"sub": "alice", "iss": "puzzles", "aud": "other"},
+	} {
+		_, err := auth.Authenticate(context.Background(), bearer(t, claims))
+		assert.Equal(t, perror.CodeUnauthorized, perror.GetErrorCode(err), name)
+	}
+
+	_, err = JWT([]byte("other")).Authenticate(context.Background(), bearer(t, map[string]any{"sub": "alice"}))
+	assert.NotNil(t, err)
+
+	p, err = auth.Authenticate(context.Background(), http.Header{})
+	assert.Nil(t, p)
+	assert.Nil(t, err)
+}
+
+func TestJWT_EmptySecret(t *testing.T) {
+	assert.Panics(t, func() { JWT(nil) })
+	assert.Panics(t, func() { JWT([]byte{}) })
+
+	_, err := SignJWT(nil, map[string]any{"sub": "alice"})
+	assert.NotNil(t, err)
+}
+
+func TestPolicy(t *testing.T) {
+	policy := NewPolicy(
+		Chain(
+			APIKey("", map[string]Principal{"key": {Subject: "bot", Roles: []string{"reader"}}}),
+			JWT(secret),
+		),
+		WithPublic("/public/*"),
+		WithRoles("/admin", "admin"),
+	)
+
+	apiKey := http.Header{"X-Api-Key": []string{"key"}}
+	ctx, err := policy.Authorize(context.Background(), "/users", apiKey)
+	assert.Nil(t, err)
+	p, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "bot", p.Subject)
+
+	_, err = policy.Authorize(context.Background(), "/admin", apiKey)
+	assert.Equal(t, ErrForbidden, err)
+
+	_, err = policy.Authorize(context.Background(), "/admin", bearer(t, map[string]any{"sub": "alice", "roles": "admin"}))
+	assert.Nil(t, err)
+
+	_, err = policy.Authorize(context.Background(), "/users", http.Header{"X-Api-Key": []string{"wrong"}})
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	_, err = policy.Authorize(context.Background(), "/users", http.Header{})
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	ctx, err = policy.Authorize(context.Background(), "/public/ping", http.Header{})
+	assert.Nil(t, err)
+	_, ok = FromContext(ctx)
+	assert.False(t, ok)
+}
@@ -0,0 +1,215 @@
+// Package pstatus converts perror errors to gRPC statuses and back.
+//
+// The perror code travels in an ErrorInfo detail of the status, so the clients
+// get the exact code back while the other gRPC clients see a meaningful status code.
+package pstatus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Domain is the domain of the ErrorInfo detail carrying the perror code
+	Domain = "go-puzzles"
+	// Reason is the reason of the ErrorInfo detail carrying the perror code
+	Reason = "PERROR"
+
+	codeKey = "code"
+)
+
+type codeRange struct {
+	min, max int
+	code     codes.Code
+}
+
+var (
+	rangesMu sync.RWMutex
+	ranges   []codeRange
+)
+
+// RegisterCodeRange maps the perror codes in [min, max] to code.
+// The ranges registered last take precedence over the previous ones and the built-in mapping.
+func RegisterCodeRange(min, max int, code codes.Code) {
+	rangesMu.Lock()
+	defer rangesMu.Unlock()
+	ranges = append(ranges, codeRange{min: min, max: max, code: code})
+}
+
+// Code returns the gRPC code of a perror code
+func Code(perrCode int) codes.Code {
+	rangesMu.RLock()
+	for i := len(ranges) - 1; i >= 0; i-- {
+		if r := ranges[i]; perrCode >= r.min && perrCode <= r.max {
+			rangesMu.RUnlock()
+			return r.code
+		}
+	}
+	rangesMu.RUnlock()
+
+	switch perrCode {
+	case perror.CodeInvalidInput:
+		return codes.InvalidArgument
+	case perror.CodeNotFound:
+		return codes.NotFound
+	case perror.CodeUnauthorized:
+		return codes.Unauthenticated
+	case perror.CodeUnknown:
+		return codes.Unknown
+	}
+
+	return httpCode(perrCode)
+}
+
+// httpCode maps the perror codes using the http status codes
+func httpCode(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+
+	switch {
+	case code >= 400 && code < 500:
+		return codes.FailedPrecondition
+	case code >= 500 && code < 600:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// PerrorCode returns the perror code matching a gRPC code, used when the status does not carry one
+func PerrorCode(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return perror.CodeInvalidInput
+	case codes.NotFound:
+		return perror.CodeNotFound
+	case codes.Unauthenticated:
+		return perror.CodeUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Internal, codes.DataLoss:
+		return http.StatusInternalServerError
+	}
+	return perror.CodeUnknown
+}
+
+// ToStatus converts err to a gRPC status. The perror errors, even wrapped, get the status code
+// of their code and an ErrorInfo detail carrying it, the statuses are kept as is.
+func ToStatus(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+
+	if ge, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return ge.GRPCStatus()
+	}
+
+	var pe perror.ErrorR
+	if !errors.As(err, &pe) {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return status.FromContextError(err)
+		}
+		// the statuses wrapped by other errors keep their code
+		st, _ := status.FromError(err)
+		return st
+	}
+
+	st := status.New(Code(pe.Code()), err.Error())
+	withDetails, derr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   Reason,
+		Domain:   Domain,
+		Metadata: map[string]string{codeKey: strconv.Itoa(pe.Code())},
+	})
+	if derr != nil {
+		return st
+	}
+	return withDetails
+}
+
+// ToError converts err to an error holding a gRPC status, see ToStatus
+func ToError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return ToStatus(err).Err()
+}
+
+// CodeOf returns the perror code carried by the status, or the one matching its gRPC code
+func CodeOf(st *status.Status) int {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == Domain && info.Reason == Reason {
+			if code, err := strconv.Atoi(info.Metadata[codeKey]); err == nil {
+				return code
+			}
+		}
+	}
+	return PerrorCode(st.Code())
+}
+
+// FromError converts an error returned by a gRPC call to a perror.ErrorR.
+// The returned error still holds the status, status.Code and status.FromError keep working on it.
+func FromError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	return &statusError{
+		ErrorR: perror.PackError(CodeOf(st), st.Message()),
+		st:     st,
+	}
+}
+
+type statusError struct {
+	perror.ErrorR
+	st *status.Status
+}
+
+func (e *statusError) GRPCStatus() *status.Status {
+	return e.st
+}
@@ -0,0 +1,46 @@
+package pstatus
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-puzzles/puzzles/perror"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRoundTrip(t *testing.T) {
+	err := fmt.Errorf("lookup: %w", perror.PackError(perror.CodeNotFound, "user not found"))
+
+	st := ToStatus(err)
+	assert.Equal(t, codes.NotFound, st.Code())
+
+	back := FromError(st.Err())
+	var pe perror.ErrorR
+	assert.True(t, errors.As(back, &pe))
+	assert.Equal(t, perror.CodeNotFound, pe.Code())
+	assert.Equal(t, codes.NotFound, status.Code(back))
+
+	teapot := FromError(ToError(perror.PackError(http.StatusTeapot, "short and stout")))
+	assert.True(t, errors.As(teapot, &pe))
+	assert.Equal(t, http.StatusTeapot, pe.Code())
+	assert.Equal(t, codes.FailedPrecondition, status.Code(teapot))
+}
+
+func TestPlainStatus(t *testing.T) {
+	err := FromError(status.Error(codes.PermissionDenied, "denied"))
+
+	var pe perror.ErrorR
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, http.StatusForbidden, pe.Code())
+	assert.Nil(t, FromError(nil))
+}
+
+func TestRegisterCodeRange(t *testing.T) {
+	RegisterCodeRange(20000, 20999, codes.Aborted)
+	assert.Equal(t, codes.Aborted, ToStatus(perror.PackError(20001, "conflict")).Code())
+	assert.Equal(t, codes.Unknown, Code(30000))
+}
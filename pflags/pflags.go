@@ -211,6 +211,7 @@ func BindPFlag(key string, flag *pflag.Flag) {
 	}
 }
 
+// GetServiceName returns an empty name until Parse has registered the service flag.
 func GetServiceName() string {
-	return serviceName()
+	return serviceName.Value()
 }
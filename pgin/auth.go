@@ -0,0 +1,33 @@
+package pgin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-puzzles/puzzles/pauth"
+	"github.com/go-puzzles/puzzles/perror"
+)
+
+// AuthMiddleware authenticates the callers with auth, the principal is read with pauth.FromContext
+// on the request context. The methods of the options are matched by the routes, like "/users/:id".
+func AuthMiddleware(auth pauth.Authenticator, opts ...pauth.Option) gin.HandlerFunc {
+	return AuthPolicyMiddleware(pauth.NewPolicy(auth, opts...))
+}
+
+// AuthPolicyMiddleware authenticates the callers and checks their roles with p
+func AuthPolicyMiddleware(p *pauth.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, err := p.Authorize(c.Request.Context(), c.FullPath(), c.Request.Header)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if perror.GetErrorCode(err) == http.StatusForbidden {
+				status = http.StatusForbidden
+			}
+			c.AbortWithStatusJSON(status, ErrorRet(perror.GetErrorCode(err), err))
+			return
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-puzzles/puzzles/plog"
+	"github.com/go-puzzles/puzzles/pmetrics"
 	"github.com/go-puzzles/puzzles/snail"
 )
 
@@ -37,7 +38,8 @@ func Default(opts ...gin.OptionFunc) *gin.Engine {
 func NewServerHandler() *gin.Engine {
 	engine := gin.New()
 
-	// default health check
+	// default health check, it only tells the engine is serving.
+	// The /healthz and /readyz routes of the core service report the health checks.
 	engine.GET("/health", func(c *gin.Context) {
 		c.String(http.StatusOK, "ok")
 	})
@@ -82,6 +84,13 @@ func WithMiddlewares(middlewares ...gin.HandlerFunc) Option {
 	}
 }
 
+// WithMetrics records the requests of the routes into r, see MetricsMiddleware
+func WithMetrics(r *pmetrics.Registry) Option {
+	return func(e *gin.Engine) {
+		e.Use(MetricsMiddleware(r))
+	}
+}
+
 func WithLoggingRequest(header bool) Option {
 	return func(e *gin.Engine) {
 		e.Use(LoggingRequest(header))
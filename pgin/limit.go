@@ -0,0 +1,29 @@
+package pgin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-puzzles/puzzles/plimit"
+)
+
+// LimitMiddleware rejects with 429 and a Retry-After header the requests over the limits of l.
+// The methods are limited by their route, like "/users/:id".
+func LimitMiddleware(l *plimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, err := l.Acquire(c.FullPath(), l.Caller(c.Request.Context(), c.Request.Header))
+		if err != nil {
+			var le *plimit.Error
+			if errors.As(err, &le) {
+				c.Header(plimit.RetryAfterKey, strconv.Itoa(le.RetryAfterSeconds()))
+			}
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, ErrorRet(http.StatusTooManyRequests, err))
+			return
+		}
+		defer release()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,34 @@
+package pgin
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-puzzles/puzzles/pmetrics"
+)
+
+// MetricsMiddleware records the count and duration of the requests handled by the gin routes
+// into r, pmetrics.Default() when r is nil. Pass the registry given to metricspuzzle.WithRegistry
+// to expose them on /metrics.
+func MetricsMiddleware(r *pmetrics.Registry) gin.HandlerFunc {
+	if r == nil {
+		r = pmetrics.Default()
+	}
+
+	requests := r.NewCounter("gin_requests_total", "Total number of requests handled by gin.", "method", "route", "code")
+	duration := r.NewHistogram("gin_request_duration_seconds", "Duration of requests handled by gin in seconds.", pmetrics.DefBuckets, "method", "route")
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		requests.Inc(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		duration.Observe(time.Since(start).Seconds(), c.Request.Method, route)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/go-puzzles/puzzles/pmetrics"
 	"gorm.io/gorm"
 )
 
@@ -18,6 +19,8 @@ type DialOption struct {
 	LogPrefix            string
 	IgnoreRecordNotFound bool
 	SlowThreshold        time.Duration
+	// Metrics is the registry the pool stats are recorded into, pmetrics.Default() when nil
+	Metrics *pmetrics.Registry
 }
 
 type DialOptionFunc func(opt *DialOption)
@@ -40,6 +43,14 @@ func WithDialThreshold(threshold time.Duration) DialOptionFunc {
 	}
 }
 
+// WithDialMetrics records the pool stats into r instead of pmetrics.Default(),
+// use the registry given to metricspuzzle.WithRegistry to expose them on /metrics.
+func WithDialMetrics(r *pmetrics.Registry) DialOptionFunc {
+	return func(opt *DialOption) {
+		opt.Metrics = r
+	}
+}
+
 type Config interface {
 	GetDBType() DbType
 	GetUid() string
@@ -50,25 +61,57 @@ type Config interface {
 type client struct {
 	db     *gorm.DB
 	config Config
+
+	stopStats func()
 }
 
 func NewClient(conf Config, opts ...DialOptionFunc) *client {
+	c, err := newClient(conf, opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return c
+}
+
+func newClient(conf Config, opts ...DialOptionFunc) (*client, error) {
 	if conf.GetService() == "" {
-		panic(fmt.Sprintf("pgorm: %v db service name can not be empty", conf.GetDBType()))
+		return nil, fmt.Errorf("pgorm: %v db service name can not be empty", conf.GetDBType())
 	}
 
 	c := &client{config: conf}
-	c.dial(opts...)
-	return c
+	if err := c.dial(opts...); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
-func (c *client) dial(opts ...DialOptionFunc) {
+func (c *client) dial(opts ...DialOptionFunc) error {
 	db, err := c.config.DialGorm(opts...)
 	if err != nil {
-		panic(fmt.Sprintf("mqlClient: new client error: %v", err))
+		return fmt.Errorf("mqlClient: new client error: %v", err)
+	}
+
+	opt := &DialOption{}
+	for _, o := range opts {
+		o(opt)
 	}
 
 	c.db = db
+	c.collectPoolStats(opt.Metrics)
+	return nil
+}
+
+// Close stops reporting the pool stats and closes the underlying db
+func (c *client) Close() error {
+	if c.stopStats != nil {
+		c.stopStats()
+	}
+
+	sqlDb, err := c.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.Close()
 }
 
 func (c *client) DB() *gorm.DB {
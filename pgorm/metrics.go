@@ -0,0 +1,35 @@
+package pgorm
+
+import (
+	"github.com/go-puzzles/puzzles/pmetrics"
+)
+
+func (c *client) collectPoolStats(r *pmetrics.Registry) {
+	sqlDb, err := c.db.DB()
+	if err != nil {
+		return
+	}
+	if r == nil {
+		r = pmetrics.Default()
+	}
+
+	poolConnections := r.NewGauge(
+		"db_pool_connections",
+		"Number of connections in the db pool by state.",
+		"db", "state",
+	)
+	poolMaxOpen := r.NewGauge(
+		"db_pool_max_open_connections",
+		"Maximum number of open connections to the db.",
+		"db",
+	)
+
+	uid := c.config.GetUid()
+	c.stopStats = r.OnCollect(func() {
+		stats := sqlDb.Stats()
+		poolConnections.Set(float64(stats.OpenConnections), uid, "open")
+		poolConnections.Set(float64(stats.InUse), uid, "in_use")
+		poolConnections.Set(float64(stats.Idle), uid, "idle")
+		poolMaxOpen.Set(float64(stats.MaxOpenConnections), uid)
+	})
+}
@@ -15,7 +15,7 @@ type SqlModel interface {
 	TableName() string
 }
 
-type getClientFunc func(opts ...DialOptionFunc) *client
+type getClientFunc func(opts ...DialOptionFunc) (*client, error)
 
 var (
 	dbInstanceClientFuncMap = make(map[string]getClientFunc)
@@ -37,7 +37,7 @@ func getDbByModel(m SqlModel, opts ...DialOptionFunc) *gorm.DB {
 		panic(fmt.Sprintf("db instance %v not found", key))
 	}
 
-	return clientFunc(opts...).DB()
+	return mustClient(clientFunc(opts...)).DB()
 }
 
 func getDbByConfig(conf Config, opts ...DialOptionFunc) *gorm.DB {
@@ -45,7 +45,14 @@ func getDbByConfig(conf Config, opts ...DialOptionFunc) *gorm.DB {
 	if !ok {
 		panic(fmt.Sprintf("db instance %v not found", conf.GetUid()))
 	}
-	return clientFunc(opts...).DB()
+	return mustClient(clientFunc(opts...)).DB()
+}
+
+func mustClient(cli *client, err error) *client {
+	if err != nil {
+		panic(err.Error())
+	}
+	return cli
 }
 
 func getInstanceClientFunc(key string) (getClientFunc, bool) {
@@ -65,13 +72,23 @@ func registerInstance(conf Config) {
 	key := conf.GetUid()
 	dbInstanceClientFuncMap[key] = func() getClientFunc {
 		var cli *client
-		var once sync.Once
-
-		f := func(opts ...DialOptionFunc) *client {
-			once.Do(func() {
-				cli = NewClient(conf, opts...)
-			})
-			return cli
+		var mu sync.Mutex
+
+		// a failed dial is not cached, the next call dials again
+		f := func(opts ...DialOptionFunc) (*client, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if cli != nil {
+				return cli, nil
+			}
+
+			c, err := newClient(conf, opts...)
+			if err != nil {
+				return nil, err
+			}
+			cli = c
+			return cli, nil
 		}
 
 		return f
@@ -140,6 +157,28 @@ func PrePing(conf Config, opts ...DialOptionFunc) error {
 	return sqlDb.Ping()
 }
 
+// HealthChecker returns a health check function pinging the db of conf
+func HealthChecker(conf Config, opts ...DialOptionFunc) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		clientFunc, ok := getInstanceClientFunc(conf.GetUid())
+		if !ok {
+			return fmt.Errorf("db instance %v not found", conf.GetUid())
+		}
+
+		cli, err := clientFunc(opts...)
+		if err != nil {
+			return err
+		}
+
+		sqlDb, err := cli.db.DB()
+		if err != nil {
+			return errors.Wrap(err, "getOriginDb")
+		}
+
+		return sqlDb.PingContext(ctx)
+	}
+}
+
 func AutoMigrate(conf Config, opts ...DialOptionFunc) error {
 	ms, exists := models[conf.GetUid()]
 	if !exists {
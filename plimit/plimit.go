@@ -0,0 +1,328 @@
+// Package plimit limits the rate and the concurrency of the requests handled by a service.
+//
+// The limits apply globally, per method and per caller. The callers are identified by a header
+// or by the subject of their pauth.Principal. The grpc puzzle and pgin wrap a
+// Limiter into interceptors and middlewares.
+package plimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-puzzles/puzzles/pauth"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// RetryAfterKey is the header, or grpc metadata, telling the rejected callers when to retry
+	RetryAfterKey = "retry-after"
+	// ReflectionMethods is the grpc reflection service, exempt by default along pauth.HealthMethods
+	ReflectionMethods = "/grpc.reflection.*"
+
+	// concurrencyRetryAfter is advised to the callers rejected by a concurrency limit
+	concurrencyRetryAfter = time.Second
+	// callerIdleTTL is the idle time after which the limits of a caller are forgotten
+	callerIdleTTL = 10 * time.Minute
+	// defaultMaxCallers is how many callers are limited on their own by default
+	defaultMaxCallers = 10000
+	// fullSweepInterval is how often the idle callers are swept while the callers are full
+	fullSweepInterval = time.Second
+)
+
+// Limit configures a token bucket rate limit and a concurrency limit, the zero fields are unlimited
+type Limit struct {
+	// Rate is the number of requests allowed per second
+	Rate float64
+	// Burst is the number of requests allowed at once on top of the rate, defaults to the rate rounded up
+	Burst int
+	// MaxInFlight is the number of requests handled at once
+	MaxInFlight int
+}
+
+// Error is returned for the rejected requests
+type Error struct {
+	// Scope is the limit rejecting the request: global, the method or the caller
+	Scope string
+	// RetryAfter is when the request may be accepted
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s limit exceeded, retry after %v", e.Scope, e.RetryAfter)
+}
+
+// Code makes the error a perror.ErrorCoder
+func (e *Error) Code() int {
+	return http.StatusTooManyRequests
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to a whole number of seconds, as the Retry-After header expects
+func (e *Error) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+type Option func(*Limiter)
+
+// WithGlobal limits all the requests together
+func WithGlobal(limit Limit) Option {
+	return func(l *Limiter) {
+		l.global = newBucket(limit)
+	}
+}
+
+// WithMethod limits the requests of a method, a full grpc method name or a gin route
+func WithMethod(method string, limit Limit) Option {
+	return func(l *Limiter) {
+		l.methods[method] = newBucket(limit)
+	}
+}
+
+// WithCaller limits the requests of each caller, identified by the key header or grpc metadata.
+// The requests without it are not limited per caller.
+func WithCaller(key string, limit Limit) Option {
+	return func(l *Limiter) {
+		l.callerKey = key
+		l.bySubject = false
+		l.callerLimit = &limit
+	}
+}
+
+// WithSubjectCaller limits the requests of each caller, identified by the subject of its pauth.Principal.
+// The authentication must run before the limits, e.g. grpcpuzzle.WithAuth is given before grpcpuzzle.WithLimits.
+// The requests without principal are not limited per caller.
+func WithSubjectCaller(limit Limit) Option {
+	return func(l *Limiter) {
+		l.callerKey = ""
+		l.bySubject = true
+		l.callerLimit = &limit
+	}
+}
+
+// WithMaxCallers sets how many callers are limited on their own, 10000 by default. Once reached,
+// the idle callers are forgotten and the new callers share a single caller limit until there is room again.
+func WithMaxCallers(n int) Option {
+	return func(l *Limiter) {
+		l.maxCallers = n
+	}
+}
+
+// WithExempt never limits the methods matching patterns, a pattern ending with * matches the methods
+// starting with it. The grpc health and reflection services are exempt by default, so that the probes
+// keep working under load.
+func WithExempt(patterns ...string) Option {
+	return func(l *Limiter) {
+		l.exempt = append(l.exempt, patterns...)
+	}
+}
+
+// Limiter admits the requests within its limits
+type Limiter struct {
+	global  *bucket
+	methods map[string]*bucket
+	exempt  []string
+
+	callerKey   string
+	bySubject   bool
+	callerLimit *Limit
+	maxCallers  int
+
+	mu        sync.Mutex
+	callers   map[string]*bucket
+	overflow  *bucket
+	lastSweep time.Time
+}
+
+func New(opts ...Option) *Limiter {
+	l := &Limiter{
+		methods:    make(map[string]*bucket),
+		exempt:     []string{pauth.HealthMethods, ReflectionMethods},
+		callers:    make(map[string]*bucket),
+		maxCallers: defaultMaxCallers,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.callerLimit != nil {
+		l.overflow = newBucket(*l.callerLimit)
+	}
+	return l
+}
+
+// CallerKey returns the header or grpc metadata identifying the callers, empty without per caller limit
+// or when the callers are identified by their principal
+func (l *Limiter) CallerKey() string {
+	return l.callerKey
+}
+
+// Caller returns the caller of a request, read from the principal of ctx or from h
+func (l *Limiter) Caller(ctx context.Context, h pauth.Header) string {
+	if l.bySubject {
+		if p, ok := pauth.FromContext(ctx); ok {
+			return p.Subject
+		}
+		return ""
+	}
+	if l.callerKey == "" {
+		return ""
+	}
+	return h.Get(l.callerKey)
+}
+
+// Acquire admits a request of caller to method, release must be called once it is handled.
+// The error is an *Error when a limit rejects the request.
+func (l *Limiter) Acquire(method, caller string) (release func(), err error) {
+	if l.isExempt(method) {
+		return func() {}, nil
+	}
+	now := time.Now()
+
+	type scoped struct {
+		scope string
+		b     *bucket
+	}
+	buckets := make([]scoped, 0, 3)
+	if l.global != nil {
+		buckets = append(buckets, scoped{"global", l.global})
+	}
+	if b, ok := l.methods[method]; ok {
+		buckets = append(buckets, scoped{method, b})
+	}
+	if b := l.caller(caller, now); b != nil {
+		buckets = append(buckets, scoped{"caller " + caller, b})
+	}
+
+	taken := make([]*bucket, 0, len(buckets))
+	reservations := make([]*rate.Reservation, 0, len(buckets))
+	for _, s := range buckets {
+		r, retryAfter, ok := s.b.take(now)
+		if !ok {
+			// give back the tokens and slots taken by the other limits
+			for i, b := range taken {
+				b.cancel(reservations[i], now)
+			}
+			return nil, &Error{Scope: s.scope, RetryAfter: retryAfter}
+		}
+		taken = append(taken, s.b)
+		reservations = append(reservations, r)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, b := range taken {
+				b.release()
+			}
+		})
+	}, nil
+}
+
+// caller returns the bucket of caller, the idle callers are swept from time to time
+func (l *Limiter) caller(caller string, now time.Time) *bucket {
+	if l.callerLimit == nil || caller == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.callers[caller]
+	if !ok {
+		full := l.maxCallers > 0 && len(l.callers) >= l.maxCallers
+		if now.Sub(l.lastSweep) > callerIdleTTL || (full && now.Sub(l.lastSweep) > fullSweepInterval) {
+			l.sweep(now)
+			full = l.maxCallers > 0 && len(l.callers) >= l.maxCallers
+		}
+		if full {
+			return l.overflow
+		}
+
+		b = newBucket(*l.callerLimit)
+		l.callers[caller] = b
+	}
+	b.lastSeen.Store(now.UnixNano())
+	return b
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for key, b := range l.callers {
+		if b.idle(now) {
+			delete(l.callers, key)
+		}
+	}
+}
+
+type bucket struct {
+	rate        *rate.Limiter
+	maxInFlight int64
+	inFlight    atomic.Int64
+	lastSeen    atomic.Int64
+}
+
+func newBucket(limit Limit) *bucket {
+	b := &bucket{maxInFlight: int64(limit.MaxInFlight)}
+	if limit.Rate > 0 {
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(limit.Rate))
+		}
+		b.rate = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+	}
+	return b
+}
+
+// take consumes a token and an in-flight slot, the reservation of the token is nil without rate limit
+func (b *bucket) take(now time.Time) (r *rate.Reservation, retryAfter time.Duration, ok bool) {
+	if b.rate != nil {
+		r = b.rate.ReserveN(now, 1)
+		if d := r.DelayFrom(now); d > 0 {
+			r.CancelAt(now)
+			return nil, d, false
+		}
+	}
+
+	if b.maxInFlight > 0 && b.inFlight.Add(1) > b.maxInFlight {
+		b.inFlight.Add(-1)
+		if r != nil {
+			r.CancelAt(now)
+		}
+		return nil, concurrencyRetryAfter, false
+	}
+	return r, 0, true
+}
+
+// release gives back the in-flight slot once the request is handled
+func (b *bucket) release() {
+	if b.maxInFlight > 0 {
+		b.inFlight.Add(-1)
+	}
+}
+
+// cancel gives back the token and the in-flight slot of a rejected request
+func (b *bucket) cancel(r *rate.Reservation, now time.Time) {
+	b.release()
+	if r != nil {
+		r.CancelAt(now)
+	}
+}
+
+// idle reports whether the bucket is unused for a while and can be forgotten
+func (b *bucket) idle(now time.Time) bool {
+	return b.inFlight.Load() == 0 && now.Sub(time.Unix(0, b.lastSeen.Load())) > callerIdleTTL
+}
+
+func (l *Limiter) isExempt(method string) bool {
+	return slices.ContainsFunc(l.exempt, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			return strings.HasPrefix(method, prefix)
+		}
+		return pattern == method
+	})
+}
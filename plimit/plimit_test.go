@@ -0,0 +1,111 @@
+package plimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-puzzles/puzzles/pauth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRate(t *testing.T) {
+	l := New(WithGlobal(Limit{Rate: 1, Burst: 2}))
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire("m", "")
+		assert.Nil(t, err)
+		release()
+	}
+
+	_, err := l.Acquire("m", "")
+	var le *Error
+	assert.True(t, errors.As(err, &le))
+	assert.Equal(t, "global", le.Scope)
+	assert.Equal(t, 1, le.RetryAfterSeconds())
+}
+
+func TestMaxInFlight(t *testing.T) {
+	l := New(WithMethod("m", Limit{MaxInFlight: 1}))
+
+	release, err := l.Acquire("m", "")
+	assert.Nil(t, err)
+
+	_, err = l.Acquire("m", "")
+	assert.NotNil(t, err)
+	_, err = l.Acquire("other", "")
+	assert.Nil(t, err)
+
+	release()
+	release()
+	_, err = l.Acquire("m", "")
+	assert.Nil(t, err)
+}
+
+func TestCallerRollback(t *testing.T) {
+	l := New(
+		WithGlobal(Limit{Rate: 1, Burst: 1}),
+		WithCaller("x-caller", Limit{MaxInFlight: 1}),
+	)
+	assert.Equal(t, "x-caller", l.CallerKey())
+
+	// hold the caller slot without consuming the global token
+	l.global = newBucket(Limit{})
+	_, err := l.Acquire("m", "alice")
+	assert.Nil(t, err)
+
+	l.global = newBucket(Limit{Rate: 1, Burst: 1})
+	_, err = l.Acquire("m", "alice")
+	var le *Error
+	assert.True(t, errors.As(err, &le))
+	assert.Equal(t, "caller alice", le.Scope)
+
+	// the global token taken by the rejected request is given back
+	_, err = l.Acquire("m", "bob")
+	assert.Nil(t, err)
+}
+
+func TestMaxCallers(t *testing.T) {
+	l := New(
+		WithCaller("x-caller", Limit{MaxInFlight: 1}),
+		WithMaxCallers(1),
+	)
+
+	_, err := l.Acquire("m", "alice")
+	assert.Nil(t, err)
+
+	// the callers over the cap share the overflow limit
+	_, err = l.Acquire("m", "bob")
+	assert.Nil(t, err)
+	_, err = l.Acquire("m", "carol")
+	var le *Error
+	assert.True(t, errors.As(err, &le))
+	assert.Len(t, l.callers, 1)
+}
+
+func TestSubjectCaller(t *testing.T) {
+	l := New(WithSubjectCaller(Limit{MaxInFlight: 1}))
+
+	h := http.Header{}
+	h.Set("x-caller", "mallory")
+	assert.Equal(t, "", l.Caller(context.Background(), h))
+
+	ctx := pauth.WithPrincipal(context.Background(), &pauth.Principal{Subject: "alice"})
+	assert.Equal(t, "alice", l.Caller(ctx, h))
+}
+
+func TestExempt(t *testing.T) {
+	l := New(WithGlobal(Limit{MaxInFlight: 1}), WithExempt("/public/*"))
+
+	_, err := l.Acquire("m", "")
+	assert.Nil(t, err)
+	_, err = l.Acquire("m", "")
+	assert.NotNil(t, err)
+
+	for _, method := range []string{"/grpc.health.v1.Health/Check", "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", "/public/ping"} {
+		_, err = l.Acquire(method, "")
+		assert.Nil(t, err, method)
+	}
+}
@@ -0,0 +1,232 @@
+// Package rpclog configures the logs of the grpc calls, shared by the grpc puzzle and the grpc dialer.
+//
+// The calls are logged at debug level with their duration, status code and peer address.
+// The payloads are captured as json on demand, the errors and the slow calls are always logged.
+package rpclog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-puzzles/puzzles/plog"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Redacted replaces the values of the redacted fields
+const Redacted = "[REDACTED]"
+
+// Config configures the logs of the grpc calls
+type Config struct {
+	// MaxPayloadBytes is the size the payloads are truncated to, 0 disables the payload capture
+	MaxPayloadBytes int
+	// RedactFields are the names, proto or json, of the fields hidden from the payloads.
+	// The proto fields marked with the debug_redact option are always hidden.
+	RedactFields []string
+	// SampleRate is the fraction of the successful calls logged, in [0, 1]
+	SampleRate float64
+	// SlowThreshold logs as warnings the calls taking longer, 0 disables it
+	SlowThreshold time.Duration
+}
+
+type Option func(*Config)
+
+// WithPayloads logs the request and response payloads as json truncated to maxBytes
+func WithPayloads(maxBytes int) Option {
+	return func(c *Config) {
+		c.MaxPayloadBytes = maxBytes
+	}
+}
+
+// WithRedactFields hides the fields named names from the payloads
+func WithRedactFields(names ...string) Option {
+	return func(c *Config) {
+		c.RedactFields = append(c.RedactFields, names...)
+	}
+}
+
+// WithSampling logs only a fraction of the successful calls, the errors and the slow calls are all logged
+func WithSampling(rate float64) Option {
+	return func(c *Config) {
+		c.SampleRate = rate
+	}
+}
+
+// WithSlowThreshold logs as warnings the calls taking longer than threshold, whatever the log level
+func WithSlowThreshold(threshold time.Duration) Option {
+	return func(c *Config) {
+		c.SlowThreshold = threshold
+	}
+}
+
+func NewConfig(opts ...Option) *Config {
+	c := &Config{SampleRate: 1}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Call is a finished grpc call
+type Call struct {
+	Method   string
+	Duration time.Duration
+	Err      error
+	Peer     string
+	// Req and Resp are the payloads of the unary calls, nil for the streams
+	Req, Resp any
+}
+
+// Enabled reports whether a call taking d may be logged, to skip the work of capturing it otherwise
+func (c *Config) Enabled(d time.Duration) bool {
+	return plog.IsDebug() || c.slow(d)
+}
+
+func (c *Config) slow(d time.Duration) bool {
+	return c.SlowThreshold > 0 && d >= c.SlowThreshold
+}
+
+// Log logs the call, verb is what was done with it, like "handle" or "invoke"
+func (c *Config) Log(ctx context.Context, verb string, call Call) {
+	slow := c.slow(call.Duration)
+	if !slow && !plog.IsDebug() {
+		return
+	}
+	if !slow && call.Err == nil && c.SampleRate < 1 && rand.Float64() >= c.SampleRate {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "method %s %s_time=%s code=%s peer=%s", call.Method, verb, call.Duration, status.Code(call.Err), call.Peer)
+	if call.Err != nil {
+		fmt.Fprintf(&b, " %s_err=%s", verb, call.Err)
+	}
+	if c.MaxPayloadBytes > 0 && (call.Req != nil || call.Resp != nil) {
+		fmt.Fprintf(&b, " req=%s resp=%s", c.Payload(call.Req), c.Payload(call.Resp))
+	}
+
+	switch {
+	case slow:
+		plog.Warnc(ctx, "Slow to %s %s", verb, b.String())
+	case call.Err != nil:
+		plog.Debugc(ctx, "Failed to %s %s", verb, b.String())
+	default:
+		plog.Debugc(ctx, "Succeed to %s %s", verb, b.String())
+	}
+}
+
+// Payload returns v as json with the redacted fields hidden, truncated to MaxPayloadBytes
+func (c *Config) Payload(v any) string {
+	if v == nil {
+		return "null"
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if m, ok := v.(proto.Message); ok {
+		m = proto.Clone(m)
+		c.redactMessage(m.ProtoReflect())
+		b, err = protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+		if err == nil {
+			// the keys of the maps and structs are redacted too
+			b, err = c.redactJSON(json.RawMessage(b))
+		}
+	} else {
+		b, err = c.redactJSON(v)
+	}
+	if err != nil {
+		return fmt.Sprintf("<marshal error: %v>", err)
+	}
+
+	if c.MaxPayloadBytes > 0 && len(b) > c.MaxPayloadBytes {
+		return fmt.Sprintf("%s...(%d bytes truncated)", b[:c.MaxPayloadBytes], len(b)-c.MaxPayloadBytes)
+	}
+	return string(b)
+}
+
+func (c *Config) redacted(fd protoreflect.FieldDescriptor) bool {
+	if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && opts.GetDebugRedact() {
+		return true
+	}
+	return slices.Contains(c.RedactFields, string(fd.Name())) || slices.Contains(c.RedactFields, fd.JSONName())
+}
+
+func (c *Config) redactMessage(m protoreflect.Message) {
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+
+	for _, fd := range fields {
+		if c.redacted(fd) {
+			if fd.Kind() == protoreflect.StringKind && fd.Cardinality() != protoreflect.Repeated {
+				m.Set(fd, protoreflect.ValueOfString(Redacted))
+			} else {
+				m.Clear(fd)
+			}
+			continue
+		}
+
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				m.Mutable(fd).Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+					c.redactMessage(v.Message())
+					return true
+				})
+			}
+		case fd.IsList():
+			if fd.Message() != nil {
+				list := m.Mutable(fd).List()
+				for i := 0; i < list.Len(); i++ {
+					c.redactMessage(list.Get(i).Message())
+				}
+			}
+		case fd.Message() != nil:
+			c.redactMessage(m.Mutable(fd).Message())
+		}
+	}
+}
+
+// redactJSON marshals v hiding the fields by their json name
+func (c *Config) redactJSON(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(c.RedactFields) == 0 {
+		return b, err
+	}
+
+	var decoded any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(c.redactValue(decoded))
+}
+
+func (c *Config) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, val := range v {
+			if slices.Contains(c.RedactFields, key) {
+				v[key] = Redacted
+			} else {
+				v[key] = c.redactValue(val)
+			}
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = c.redactValue(val)
+		}
+	}
+	return v
+}
@@ -0,0 +1,94 @@
+package rpclog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-puzzles/puzzles/plog"
+	"github.com/go-puzzles/puzzles/plog/level"
+	"github.com/go-puzzles/puzzles/plog/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newLoginRequest returns a message whose password field has the debug_redact option
+func newLoginRequest(t *testing.T, user, password string) *dynamicpb.Message {
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("login.proto"),
+		Package: proto.String("rpclog"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("LoginRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("user"), JsonName: proto.String("user"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{
+					Name: proto.String("password"), JsonName: proto.String("password"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					Options: &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)},
+				},
+			},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md := fd.Messages().Get(0)
+	m := dynamicpb.NewMessage(md)
+	m.Set(md.Fields().ByName("user"), protoreflect.ValueOfString(user))
+	m.Set(md.Fields().ByName("password"), protoreflect.ValueOfString(password))
+	return m
+}
+
+func TestPayload(t *testing.T) {
+	conf := NewConfig(WithRedactFields("token"))
+
+	assert.JSONEq(t, `{"user":"alice","password":"[REDACTED]"}`, conf.Payload(newLoginRequest(t, "alice", "secret")))
+
+	nested, err := structpb.NewStruct(map[string]any{"token": "secret", "name": "alice"})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"token":"[REDACTED]","name":"alice"}`, conf.Payload(nested))
+
+	type plain struct {
+		Token string `json:"token"`
+		Name  string `json:"name"`
+	}
+	assert.JSONEq(t, `{"token":"[REDACTED]","name":"alice"}`, conf.Payload(&plain{Token: "secret", Name: "alice"}))
+
+	conf = NewConfig(WithPayloads(8))
+	assert.Equal(t, `{"name":...(8 bytes truncated)`, conf.Payload(map[string]string{"name": "alice"}))
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New()
+	l.SetOutput(&buf)
+	old := plog.GetLogger()
+	plog.SetLogger(l)
+	defer plog.SetLogger(old)
+
+	conf := NewConfig(WithSampling(0), WithSlowThreshold(time.Second))
+	ctx := context.Background()
+
+	// the fast successful calls are sampled out, the slow ones logged whatever the level
+	conf.Log(ctx, "handle", Call{Method: "/svc/Fast", Duration: time.Millisecond})
+	conf.Log(ctx, "handle", Call{Method: "/svc/Slow", Duration: 2 * time.Second, Peer: "10.0.0.1:1234"})
+	assert.NotContains(t, buf.String(), "/svc/Fast")
+	assert.Contains(t, buf.String(), "Slow to handle method /svc/Slow")
+	assert.Contains(t, buf.String(), "code=OK peer=10.0.0.1:1234")
+
+	l.Enable(level.LevelDebug)
+	conf.Log(ctx, "handle", Call{Method: "/svc/Fast", Duration: time.Millisecond})
+	conf.Log(ctx, "handle", Call{Method: "/svc/Failed", Duration: time.Millisecond, Err: errors.New("boom")})
+	assert.NotContains(t, buf.String(), "/svc/Fast")
+	assert.Equal(t, 1, strings.Count(buf.String(), "Failed to handle method /svc/Failed"))
+	assert.Contains(t, buf.String(), "code=Unknown")
+}
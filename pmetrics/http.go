@@ -0,0 +1,77 @@
+package pmetrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijack")
+	}
+	return h.Hijack()
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// InstrumentHandler records the count, duration and in flight number of the requests served by next.
+// Requests are labeled by the pattern of the http.ServeMux route to keep the cardinality low.
+func InstrumentHandler(r *Registry, next http.Handler) http.Handler {
+	requests := r.NewCounter("http_requests_total", "Total number of http requests.", "method", "path", "code")
+	duration := r.NewHistogram("http_request_duration_seconds", "Duration of http requests in seconds.", DefBuckets, "method", "path")
+	inFlight := r.NewGauge("http_requests_in_flight", "Number of http requests being served.")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, req)
+
+		path := req.Pattern
+		if path == "" {
+			path = "unmatched"
+		}
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		requests.Inc(req.Method, path, strconv.Itoa(status))
+		duration.Observe(time.Since(start).Seconds(), req.Method, path)
+	})
+}
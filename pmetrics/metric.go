@@ -0,0 +1,183 @@
+package pmetrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// Counter is a monotonically increasing value partitioned by label values
+type Counter struct {
+	*desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter, negative values are ignored
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the current value of the series, mostly useful in tests
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeSeries(w, c.desc, &c.mu, c.series)
+}
+
+// Gauge is a value that can go up and down partitioned by label values
+type Gauge struct {
+	*desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func (g *Gauge) update(fn func(s *series), labelValues []string) {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s, ok := g.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		g.series[key] = s
+	}
+	fn(s)
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.update(func(s *series) { s.value = v }, labelValues)
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.update(func(s *series) { s.value += v }, labelValues)
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Value returns the current value of the series, mostly useful in tests
+func (g *Gauge) Value(labelValues ...string) float64 {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if s, ok := g.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (g *Gauge) write(w io.Writer) {
+	writeSeries(w, g.desc, &g.mu, g.series)
+}
+
+func writeSeries(w io.Writer, d *desc, mu *sync.Mutex, m map[string]*series) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	d.writeHeader(w)
+	for _, key := range sortedSeries(m) {
+		s := m[key]
+		fmt.Fprintf(w, "%s%s %s\n", d.name, formatLabels(d.labels, s.labelValues), formatValue(s.value))
+	}
+}
+
+// Histogram samples observations into cumulative buckets partitioned by label values
+type Histogram struct {
+	*desc
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count returns how many observations the series has, mostly useful in tests
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range sortedSeries(h.series) {
+		s := h.series[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.labelValues, "le", formatValue(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.labelValues, "le", formatValue(math.Inf(1))), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.labelValues), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labelValues), s.count)
+	}
+}
@@ -0,0 +1,258 @@
+// Package pmetrics is a small metrics registry supporting counters, gauges and
+// histograms which are exposed in the Prometheus text exposition format.
+package pmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// DefBuckets are the default histogram buckets, tailored to request durations in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	describe() *desc
+	write(w io.Writer)
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    metricType
+	labels []string
+}
+
+func (d *desc) describe() *desc {
+	return d
+}
+
+func (d *desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("pmetrics: %s expects %d label values, got %d", d.name, len(d.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (d *desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]metric
+	hooks   []*collectHook
+}
+
+type collectHook struct {
+	fn func()
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process wide registry, it is where the pool stats of the dialers
+// are recorded unless they are given another registry.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+	}
+}
+
+// register returns the already registered metric with the same name, so that
+// instrumentation can be declared multiple times. It panics on a type or label mismatch.
+func (r *Registry) register(m metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := m.describe()
+	if exists, ok := r.metrics[d.name]; ok {
+		ed := exists.describe()
+		if ed.typ != d.typ || strings.Join(ed.labels, ",") != strings.Join(d.labels, ",") {
+			panic(fmt.Sprintf("pmetrics: %s already registered with a different type or labels", d.name))
+		}
+		return exists
+	}
+
+	r.metrics[d.name] = m
+	return m
+}
+
+// OnCollect registers a hook called before every collection, it is used to
+// update gauges from external sources like connection pool stats.
+// The returned func removes the hook, it must be called once the source is closed.
+func (r *Registry) OnCollect(hook func()) (unregister func()) {
+	h := &collectHook{fn: hook}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, h)
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.hooks = slices.DeleteFunc(r.hooks, func(e *collectHook) bool {
+			return e == h
+		})
+	}
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return r.register(&Counter{
+		desc:   &desc{name: name, help: help, typ: counterType, labels: labels},
+		series: make(map[string]*series),
+	}).(*Counter)
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return r.register(&Gauge{
+		desc:   &desc{name: name, help: help, typ: gaugeType, labels: labels},
+		series: make(map[string]*series),
+	}).(*Gauge)
+}
+
+// NewHistogram creates a histogram with the given upper bounds, DefBuckets is used when buckets is empty
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return r.register(&Histogram{
+		desc:    &desc{name: name, help: help, typ: histogramType, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}).(*Histogram)
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	hooks := slices.Clone(r.hooks)
+	r.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook.fn()
+	}
+
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_ = r.WriteText(w)
+}
+
+// Handler returns the http handler serving the metrics of the registry
+func Handler(r *Registry) http.Handler {
+	return r
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return defaultRegistry.NewCounter(name, help, labels...)
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return defaultRegistry.NewGauge(name, help, labels...)
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return defaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+func OnCollect(hook func()) (unregister func()) {
+	return defaultRegistry.OnCollect(hook)
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+func sortedSeries[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
@@ -0,0 +1,111 @@
+package pmetrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounter("http_requests_total", "Total http requests.", "method", "code")
+	requests.Inc("GET", "200")
+	requests.Add(2, "GET", "200")
+	requests.Inc("POST", "500")
+
+	inFlight := r.NewGauge("in_flight", "In flight requests.")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+
+	duration := r.NewHistogram("duration_seconds", "Request duration.", []float64{0.1, 1}, "path")
+	duration.Observe(0.05, "/a")
+	duration.Observe(0.5, "/a")
+	duration.Observe(5, "/a")
+
+	pool := r.NewGauge("pool_open", "Open connections.", "db")
+	r.OnCollect(func() {
+		pool.Set(3, `x"y`)
+	})
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, r.WriteText(buf))
+
+	expected := `# HELP duration_seconds Request duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{path="/a",le="0.1"} 1
+duration_seconds_bucket{path="/a",le="1"} 2
+duration_seconds_bucket{path="/a",le="+Inf"} 3
+duration_seconds_sum{path="/a"} 5.55
+duration_seconds_count{path="/a"} 3
+# HELP http_requests_total Total http requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 3
+http_requests_total{method="POST",code="500"} 1
+# HELP in_flight In flight requests.
+# TYPE in_flight gauge
+in_flight 1
+# HELP pool_open Open connections.
+# TYPE pool_open gauge
+pool_open{db="x\"y"} 3
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestRegistry_OnCollectUnregister(t *testing.T) {
+	r := NewRegistry()
+
+	calls := 0
+	unregister := r.OnCollect(func() { calls++ })
+	assert.Nil(t, r.WriteText(&bytes.Buffer{}))
+	assert.Equal(t, 1, calls)
+
+	unregister()
+	assert.Nil(t, r.WriteText(&bytes.Buffer{}))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRegistry_RegisterTwice(t *testing.T) {
+	r := NewRegistry()
+
+	c1 := r.NewCounter("jobs_total", "Jobs.", "job")
+	c2 := r.NewCounter("jobs_total", "Jobs.", "job")
+	c1.Inc("a")
+	c2.Inc("a")
+	assert.Equal(t, float64(2), c1.Value("a"))
+
+	assert.Panics(t, func() { r.NewGauge("jobs_total", "Jobs.", "job") })
+	assert.Panics(t, func() { c1.Inc() })
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("up", "Up.").Inc()
+
+	rec := httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "up 1\n")
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	r := NewRegistry()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	h := InstrumentHandler(r, mux)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/2", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, r.WriteText(buf))
+	assert.Contains(t, buf.String(), `http_requests_total{method="GET",path="GET /users/{id}",code="201"} 2`)
+	assert.Contains(t, buf.String(), `http_requests_total{method="GET",path="unmatched",code="404"} 1`)
+}
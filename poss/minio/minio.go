@@ -61,6 +61,19 @@ func NewMinioOss(conf *MinioConfig) *MinioOss {
 	return m
 }
 
+// HealthCheck checks the configured bucket is reachable, it can be registered as a health checker
+func (m *MinioOss) HealthCheck(ctx context.Context) error {
+	exists, err := m.client.BucketExists(ctx, m.Bucket)
+	if err != nil {
+		return errors.Wrap(err, "bucketExists")
+	}
+	if !exists {
+		return errors.Errorf("bucket %s not exists", m.Bucket)
+	}
+
+	return nil
+}
+
 func (m *MinioOss) Init(router gin.IRouter) {
 	minioGroup := router.Group("minio")
 	minioGroup.GET(":sourceType/:sourceName", pgin.RequestHandler(m.getMinioSourceHandler))
@@ -50,6 +50,18 @@ func (rc *RedisClient) GetConnWithContext(ctx context.Context) (redis.Conn, erro
 	return rc.pool.GetContext(ctx)
 }
 
+// HealthCheck pings the redis server, it can be registered as a health checker
+func (rc *RedisClient) HealthCheck(ctx context.Context) error {
+	conn, err := rc.GetConnWithContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getConn")
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PING")
+	return err
+}
+
 func (rc *RedisClient) Do(command string, args ...any) (reply any, err error) {
 	conn := rc.GetConn()
 	defer conn.Close()
@@ -687,5 +699,5 @@ func randomValue() string {
 }
 
 func (rc *RedisClient) Close() error {
-	return rc.pool.Close()
+	return redisDialer.CloseRedisPool(rc.pool)
 }
@@ -0,0 +1,240 @@
+// Package ptls loads TLS certificates from disk and reloads them when the files change,
+// so that rotated certificates are picked up without restarting the process.
+package ptls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-puzzles/puzzles/plog"
+	"github.com/pkg/errors"
+)
+
+const defaultCheckInterval = 10 * time.Second
+
+// Reloader holds a certificate and a CA pool loaded from files.
+// The files are checked for changes at most once per check interval when the certificate or the pool is used.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+type ReloaderOption func(r *Reloader)
+
+// WithCA loads the CA bundle used to verify the peer certificates.
+func WithCA(caFile string) ReloaderOption {
+	return func(r *Reloader) {
+		r.caFile = caFile
+	}
+}
+
+// WithCheckInterval sets how often the files are checked for changes, the default is 10s.
+func WithCheckInterval(interval time.Duration) ReloaderOption {
+	return func(r *Reloader) {
+		r.interval = interval
+	}
+}
+
+// NewReloader loads the key pair and the CA of the options.
+// certFile and keyFile may be empty for a client which does not present a certificate.
+func NewReloader(certFile, keyFile string, opts ...ReloaderOption) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: defaultCheckInterval,
+		modTimes: make(map[string]time.Time),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (r *Reloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return errors.Wrap(err, "stat")
+		}
+		modTimes[f] = fi.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return errors.Wrap(err, "loadX509KeyPair")
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return errors.Wrap(err, "readCA")
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = cert
+	r.pool = pool
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for f, modTime := range r.modTimes {
+		fi, err := os.Stat(f)
+		if err != nil {
+			// the file may be being replaced, keep the loaded one
+			continue
+		}
+		if !fi.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.checkedAt) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+
+	if !r.changed() {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		plog.Errorf("Reload certificate %s error, keep using the loaded one: %v", r.certFile, err)
+		return
+	}
+	plog.Infof("Certificate reloaded. Cert=%s CA=%s", r.certFile, r.caFile)
+}
+
+// Certificate returns the current key pair
+func (r *Reloader) Certificate() (*tls.Certificate, error) {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return nil, errors.New("no certificate configured")
+	}
+	return r.cert, nil
+}
+
+// CertPool returns the current CA pool, nil if no CA is configured
+func (r *Reloader) CertPool() *x509.CertPool {
+	r.maybeReload()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// ServerConfig returns a server config advertising h2 and http/1.1 through ALPN.
+// If requireClientCert is set, clients must present a certificate signed by the CA.
+func (r *Reloader) ServerConfig(requireClientCert bool) *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate()
+		},
+	}
+
+	if !requireClientCert {
+		return base
+	}
+
+	cfg := base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		c.ClientCAs = r.CertPool()
+		return c, nil
+	}
+	return cfg
+}
+
+// ClientConfig returns a client config verifying the server against the CA, or the system roots
+// if no CA is configured, and presenting the certificate if one is configured.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if r.certFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate()
+		}
+	}
+
+	if r.caFile != "" {
+		// the default verification can not use a pool which changes over time,
+		// the chain is verified against the current pool in VerifyConnection instead
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("server did not present a certificate")
+			}
+
+			opts := x509.VerifyOptions{
+				DNSName:       cs.ServerName,
+				Roots:         r.CertPool(),
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+
+	return cfg
+}